@@ -0,0 +1,338 @@
+package nagaya
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Connector is a [driver.Connector] that switches each connection to the tenant bound for the context.
+//
+// The tenant is taken by [TenantFromContext] on each query, exec, prepare and beginning of a transaction,
+// so the [*sql.DB] opened by [sql.OpenDB] with the Connector is tenant-transparent.
+// The prepared statement is prepared again when it is executed for another tenant than the one it was prepared for.
+// If no tenant is bound for the context, the connection is restored by [TenantSwitcher.Reset].
+//
+// Note that the tenant of the transaction is decided when it begins;
+// the statements in the transaction run against that tenant regardless of the tenant bound for their contexts.
+type Connector struct {
+	base     driver.Connector
+	tracer   trace.Tracer
+	switcher TenantSwitcher
+	rule     *TenantRule
+}
+
+var (
+	_ driver.Connector = (*Connector)(nil)
+	_ io.Closer        = (*Connector)(nil)
+)
+
+// NewConnector returns a new [Connector] that wraps the base connector.
+//
+// It accepts the same options as [New] such as [WithTenantSwitcher].
+// The hooks such as [AfterBind] are not run by the Connector because it does not know the [Connish] connections.
+func NewConnector(base driver.Connector, opts ...NewOption) *Connector {
+	cfg := new(newConfig)
+	for _, o := range opts {
+		o.applyNewOption(cfg)
+	}
+	switcher := cfg.switcher
+	if switcher == nil {
+		switcher = new(MySQLTenantSwitcher)
+	}
+	rule := cfg.rule
+	if rule == nil {
+		rule = defaultTenantRule
+	}
+	return &Connector{base: base, tracer: getTracer(cfg.tp), switcher: switcher, rule: rule}
+}
+
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantConn{Conn: conn, connector: c}, nil
+}
+
+func (c *Connector) Driver() driver.Driver { return c.base.Driver() }
+
+// Close closes the base connector if it implements [io.Closer].
+func (c *Connector) Close() error {
+	if closer, ok := c.base.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type tenantConn struct {
+	driver.Conn
+	connector *Connector
+	tenant    Tenant
+	broken    bool
+	// inTx tells a transaction is open so that the tenant must not be switched until it ends.
+	inTx bool
+}
+
+var (
+	_ driver.ConnPrepareContext = (*tenantConn)(nil)
+	_ driver.ExecerContext      = (*tenantConn)(nil)
+	_ driver.QueryerContext     = (*tenantConn)(nil)
+	_ driver.ConnBeginTx        = (*tenantConn)(nil)
+	_ driver.Pinger             = (*tenantConn)(nil)
+	_ driver.SessionResetter    = (*tenantConn)(nil)
+	_ driver.Validator          = (*tenantConn)(nil)
+	_ driver.NamedValueChecker  = (*tenantConn)(nil)
+)
+
+// bind switches the connection to the tenant bound for the context if it differs from the current one.
+func (c *tenantConn) bind(ctx context.Context) (err error) {
+	if c.broken {
+		return driver.ErrBadConn
+	}
+	if c.inTx {
+		return nil
+	}
+	tenant, _ := TenantFromContext(ctx)
+	if tenant == c.tenant {
+		return nil
+	}
+
+	ctx, span := c.connector.tracer.Start(ctx, "Nagaya.Connector.SwitchTenant", trace.WithAttributes(attrTenant(tenant)))
+	defer func() { finishSpan(span, err) }()
+
+	execer := driverExecer{conn: c.Conn}
+	if tenant == "" {
+		resetCtx, cancel := context.WithTimeout(ctx, defaultChangeTenantTimeout)
+		defer cancel()
+		if err := c.connector.switcher.Reset(resetCtx, execer); err != nil {
+			// the connection still points to the previous tenant, so let database/sql take another one.
+			c.broken = true
+			return driver.ErrBadConn
+		}
+		c.tenant = ""
+		return nil
+	}
+	if err := c.connector.rule.Validate(tenant); err != nil {
+		return err
+	}
+	if err := switchTenant(ctx, c.connector.switcher, execer, tenant, defaultChangeTenantTimeout); err != nil {
+		c.broken = true
+		return err
+	}
+	c.tenant = tenant
+	return nil
+}
+
+func (c *tenantConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.bind(ctx); err != nil {
+		return nil, err
+	}
+	stmt, err := prepareDriverStmt(ctx, c.Conn, query)
+	if err != nil {
+		return nil, err
+	}
+	return &tenantStmt{Stmt: stmt, conn: c, query: query, tenant: c.tenant}, nil
+}
+
+func (c *tenantConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.bind(ctx); err != nil {
+		return nil, err
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *tenantConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.bind(ctx); err != nil {
+		return nil, err
+	}
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *tenantConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.bind(ctx); err != nil {
+		return nil, err
+	}
+	tx, err := c.begin(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &tenantTx{Tx: tx, conn: c}, nil
+}
+
+func (c *tenantConn) begin(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) || opts.ReadOnly {
+		return nil, errUnsupportedTxOptions
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for the drivers that do not implement driver.ConnBeginTx
+}
+
+// tenantTx lets the connection switch the tenant again once the transaction ends.
+type tenantTx struct {
+	driver.Tx
+	conn *tenantConn
+}
+
+func (tx *tenantTx) Commit() error {
+	tx.conn.inTx = false
+	return tx.Tx.Commit()
+}
+
+func (tx *tenantTx) Rollback() error {
+	tx.conn.inTx = false
+	return tx.Tx.Rollback()
+}
+
+func (c *tenantConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tenantConn) ResetSession(ctx context.Context) error {
+	if c.broken {
+		return driver.ErrBadConn
+	}
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tenantConn) IsValid() bool {
+	if c.broken {
+		return false
+	}
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *tenantConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// tenantStmt is the prepared statement that follows the tenant bound for the context of each execution.
+//
+// The statement is prepared against the tenant at the time, so it is prepared again once the connection is switched to another tenant.
+type tenantStmt struct {
+	driver.Stmt
+	conn   *tenantConn
+	query  string
+	tenant Tenant
+}
+
+var (
+	_ driver.StmtExecContext   = (*tenantStmt)(nil)
+	_ driver.StmtQueryContext  = (*tenantStmt)(nil)
+	_ driver.NamedValueChecker = (*tenantStmt)(nil)
+)
+
+// bind switches the connection to the tenant bound for the context and prepares the statement again if the tenant differs from the prepared one.
+func (s *tenantStmt) bind(ctx context.Context) error {
+	if err := s.conn.bind(ctx); err != nil {
+		return err
+	}
+	if s.tenant == s.conn.tenant {
+		return nil
+	}
+	stmt, err := prepareDriverStmt(ctx, s.conn.Conn, s.query)
+	if err != nil {
+		return err
+	}
+	_ = s.Stmt.Close()
+	s.Stmt, s.tenant = stmt, s.conn.tenant
+	return nil
+}
+
+func (s *tenantStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.bind(ctx); err != nil {
+		return nil, err
+	}
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	return s.Stmt.Exec(driverValues(args)) //nolint:staticcheck // fallback for the drivers that do not implement driver.StmtExecContext
+}
+
+func (s *tenantStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.bind(ctx); err != nil {
+		return nil, err
+	}
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	return s.Stmt.Query(driverValues(args)) //nolint:staticcheck // fallback for the drivers that do not implement driver.StmtQueryContext
+}
+
+func (s *tenantStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+var errUnsupportedTxOptions = errors.New("the driver does not support non-default transaction options")
+
+// driverExecer adapts [driver.Conn] to [Execer] so that [TenantSwitcher] can switch it.
+type driverExecer struct{ conn driver.Conn }
+
+var _ Execer = driverExecer{}
+
+func (e driverExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	if execer, ok := e.conn.(driver.ExecerContext); ok {
+		ret, err := execer.ExecContext(ctx, query, nvs)
+		if !errors.Is(err, driver.ErrSkip) {
+			return ret, err
+		}
+	}
+	stmt, err := prepareDriverStmt(ctx, e.conn, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = stmt.Close() }()
+	if execer, ok := stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, nvs)
+	}
+	return stmt.Exec(driverValues(nvs)) //nolint:staticcheck // fallback for the drivers that do not implement driver.StmtExecContext
+}
+
+func prepareDriverStmt(ctx context.Context, conn driver.Conn, query string) (driver.Stmt, error) {
+	if preparer, ok := conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return conn.Prepare(query)
+}
+
+func driverValues(nvs []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(nvs))
+	for i, nv := range nvs {
+		values[i] = nv.Value
+	}
+	return values
+}
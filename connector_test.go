@@ -0,0 +1,210 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aereal/nagaya"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestConnector(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		switcher       *nagaya.MySQLTenantSwitcher
+		wantNoTenantDB string
+	}{
+		{
+			name:           "discard the connection",
+			switcher:       &nagaya.MySQLTenantSwitcher{},
+			wantNoTenantDB: "tenant_default",
+		},
+		{
+			name:           "restore the default tenant",
+			switcher:       &nagaya.MySQLTenantSwitcher{DefaultTenant: "tenant_3"},
+			wantNoTenantDB: "tenant_3",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, err := openTenantTransparentDBForTesting(nagaya.WithTenantSwitcher(tc.switcher))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = db.Close() })
+			// all queries share the one connection so that the tenant must be switched on each query.
+			db.SetMaxOpenConns(1)
+			db.SetMaxIdleConns(1)
+
+			for _, tenant := range []nagaya.Tenant{"tenant_1", "tenant_2", "tenant_1"} {
+				ctx := nagaya.WithTenant(t.Context(), tenant)
+				if got := queryCurrentDBName(ctx, t, db); got != string(tenant) {
+					t.Errorf("database():\n\twant: %s\n\t got: %s", tenant, got)
+				}
+			}
+			if got := queryCurrentDBName(t.Context(), t, db); got != tc.wantNoTenantDB {
+				t.Errorf("database() without tenant:\n\twant: %s\n\t got: %s", tc.wantNoTenantDB, got)
+			}
+
+			ctx := nagaya.WithTenant(t.Context(), "tenant_2")
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = tx.Rollback() }()
+			if _, err := tx.ExecContext(ctx, "insert users values ()"); err != nil {
+				t.Fatal(err)
+			}
+			var dbName string
+			if err := tx.QueryRowContext(ctx, "select database()").Scan(&dbName); err != nil {
+				t.Fatal(err)
+			}
+			if dbName != "tenant_2" {
+				t.Errorf("database() in the transaction:\n\twant: %s\n\t got: %s", "tenant_2", dbName)
+			}
+		})
+	}
+}
+
+func TestConnector_invalidTenant(t *testing.T) {
+	t.Parallel()
+
+	db, err := openTenantTransparentDBForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx := nagaya.WithTenant(t.Context(), "x; drop database y")
+	if _, err := db.ExecContext(ctx, "select 1"); !errors.Is(err, nagaya.ErrInvalidTenant) {
+		t.Errorf("expected ErrInvalidTenant but got: %v", err)
+	}
+}
+
+func TestConnector_tenantOfTransaction(t *testing.T) {
+	t.Parallel()
+
+	switcher := &recordingSwitcher{}
+	db := openStubDBForTesting(t, nagaya.NewConnector(stubConnector{}, nagaya.WithTenantSwitcher(switcher)))
+	db.SetMaxOpenConns(1)
+
+	ctx := nagaya.WithTenant(t.Context(), "tenant_1")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmtCtx := range []context.Context{nagaya.WithTenant(t.Context(), "tenant_2"), t.Context()} {
+		if _, err := tx.ExecContext(stmtCtx, "insert users values ()"); err != nil {
+			t.Fatalf("the statement in the transaction must not switch the tenant: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(nagaya.WithTenant(t.Context(), "tenant_2"), "insert users values ()"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []nagaya.Tenant{"tenant_1", "tenant_2"}; !slices.Equal(want, switcher.switched) {
+		t.Errorf("switched tenants:\n\twant: %v\n\t got: %v", want, switcher.switched)
+	}
+}
+
+func TestConnector_preparedStatement(t *testing.T) {
+	t.Parallel()
+
+	connector := new(preparingConnector)
+	db := openStubDBForTesting(t, nagaya.NewConnector(connector))
+	db.SetMaxOpenConns(1)
+
+	stmt, err := db.PrepareContext(nagaya.WithTenant(t.Context(), "tenant_1"), "insert users values ()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = stmt.Close() })
+	for _, tenant := range []nagaya.Tenant{"tenant_1", "tenant_2"} {
+		if _, err := stmt.ExecContext(nagaya.WithTenant(t.Context(), tenant), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"`tenant_1`", "`tenant_2`"}; !slices.Equal(want, connector.executed) {
+		t.Errorf("the databases that the statement is prepared for:\n\twant: %v\n\t got: %v", want, connector.executed)
+	}
+}
+
+// preparingConnector is a [driver.Connector] that records the database for which each executed statement is prepared.
+type preparingConnector struct {
+	stubConnector
+	executed []string
+}
+
+func (c *preparingConnector) Connect(context.Context) (driver.Conn, error) {
+	return &preparingConn{connector: c}, nil
+}
+
+type preparingConn struct {
+	stubConn
+	connector *preparingConnector
+	database  string
+}
+
+func (c *preparingConn) Prepare(string) (driver.Stmt, error) {
+	return &preparedStmt{conn: c, database: c.database}, nil
+}
+
+func (c *preparingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if database, ok := strings.CutPrefix(query, "use "); ok {
+		c.database = database
+	}
+	return driver.RowsAffected(0), nil
+}
+
+type preparedStmt struct {
+	conn     *preparingConn
+	database string
+}
+
+func (s *preparedStmt) Close() error { return nil }
+
+func (s *preparedStmt) NumInput() int { return -1 }
+
+func (s *preparedStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.conn.connector.executed = append(s.conn.connector.executed, s.database)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *preparedStmt) Query([]driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+func openTenantTransparentDBForTesting(opts ...nagaya.NewOption) (*sql.DB, error) {
+	dsn := os.Getenv(envTestDBDSN)
+	if dsn == "" {
+		return nil, errDSNRequired
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	base, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(nagaya.NewConnector(base, opts...)), nil
+}
+
+func queryCurrentDBName(ctx context.Context, t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var dbName string
+	if err := db.QueryRowContext(ctx, "select database()").Scan(&dbName); err != nil {
+		t.Fatal(err)
+	}
+	return dbName
+}
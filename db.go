@@ -30,3 +30,8 @@ type Connish interface {
 	QueryRowContext(context.Context, string, ...any) *sql.Row
 	Close() error
 }
+
+// Execer is a subset of [Connish] that only executes a statement.
+type Execer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
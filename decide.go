@@ -0,0 +1,172 @@
+package nagaya
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// DecideTenantFromSubdomain returns a [DecideRequestTenantFunc] that uses the subdomain of the request host as the tenant.
+//
+// For example, the request to acme.example.com is bound for the tenant acme.
+// If the host has no subdomain or the subdomain is excluded, it decides [TenantDecisionResultNoChange].
+// The host is compared case-insensitively and its port is stripped by default.
+func DecideTenantFromSubdomain(opts ...SubdomainOption) DecideRequestTenantFunc {
+	cfg := &subdomainConfig{stripPort: true}
+	for _, o := range opts {
+		o.applySubdomainOption(cfg)
+	}
+	return func(r *http.Request) TenantDecisionResult {
+		host := strings.ToLower(r.Host)
+		if cfg.stripPort {
+			host = stripPort(host)
+		}
+		subdomain, ok := cfg.subdomain(host)
+		if !ok {
+			if cfg.baseDomain != "" && host != cfg.baseDomain {
+				return &TenantDecisionResultError{Err: ErrNoTenantBound}
+			}
+			return TenantDecisionResultNoChange{}
+		}
+		if slices.Contains(cfg.excluded, subdomain) {
+			return TenantDecisionResultNoChange{}
+		}
+		if cfg.mapper == nil {
+			return &TenantDecisionResultChangeTenant{Tenant: Tenant(subdomain)}
+		}
+		tenant, err := cfg.mapper(subdomain)
+		if err != nil {
+			return &TenantDecisionResultError{Err: err}
+		}
+		return &TenantDecisionResultChangeTenant{Tenant: tenant}
+	}
+}
+
+// subdomain returns the leftmost label of the host that precedes the base domain.
+func (cfg *subdomainConfig) subdomain(host string) (string, bool) {
+	var rest string
+	if cfg.baseDomain != "" {
+		var found bool
+		rest, found = strings.CutSuffix(host, "."+cfg.baseDomain)
+		if !found {
+			return "", false
+		}
+	} else {
+		if net.ParseIP(host) != nil {
+			return "", false
+		}
+		labels := strings.Split(host, ".")
+		if len(labels) < 3 {
+			return "", false
+		}
+		rest = strings.Join(labels[:len(labels)-2], ".")
+	}
+	subdomain, _, _ := strings.Cut(rest, ".")
+	return subdomain, subdomain != ""
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// HeaderTenant returns a [DecideRequestTenantFunc] that uses given header value as the tenant.
+//
+// If the header is missing, it decides [TenantDecisionResultError] with [ErrNoTenantBound].
+// [DecideTenantFromHeader] is a shorthand to use it with [Middleware].
+func HeaderTenant(headerName string) DecideRequestTenantFunc {
+	return func(r *http.Request) TenantDecisionResult {
+		tenant := r.Header.Get(headerName)
+		if tenant == "" {
+			return &TenantDecisionResultError{Err: ErrNoTenantBound}
+		}
+		return &TenantDecisionResultChangeTenant{Tenant: Tenant(tenant)}
+	}
+}
+
+// FirstOf returns a [DecideRequestTenantFunc] that tries the functions in order and returns the first decision that changes the tenant.
+//
+// [TenantDecisionResultNoChange] means the function has no opinion, so the next function is tried.
+// [TenantDecisionResultError] with [ErrNoTenantBound] is also passed over, but if no function changes the tenant the first one is decided.
+// The other errors such as an invalid token are decided at once, so that the later functions cannot override them.
+// Otherwise it decides [TenantDecisionResultNoChange].
+func FirstOf(fns ...DecideRequestTenantFunc) DecideRequestTenantFunc {
+	return func(r *http.Request) TenantDecisionResult {
+		var firstErr TenantDecisionResult
+		for _, fn := range fns {
+			ret := fn(r)
+			switch ret.Decision() {
+			case TenantDecisionChangeTenant:
+				return ret
+			case TenantDecisionError:
+				if _, err := ret.DecideTenant(); !errors.Is(err, ErrNoTenantBound) {
+					return ret
+				}
+				if firstErr == nil {
+					firstErr = ret
+				}
+			case TenantDecisionNoChange:
+			}
+		}
+		if firstErr != nil {
+			return firstErr
+		}
+		return TenantDecisionResultNoChange{}
+	}
+}
+
+// Fallback returns a [DecideRequestTenantFunc] that decides given tenant if the function cannot find any tenant.
+//
+// It replaces [TenantDecisionResultNoChange] and [TenantDecisionResultError] with [ErrNoTenantBound].
+// The other errors such as an invalid token are kept as is, because they are not something to be replaced silently.
+func Fallback(fn DecideRequestTenantFunc, tenant Tenant) DecideRequestTenantFunc {
+	return func(r *http.Request) TenantDecisionResult {
+		ret := fn(r)
+		switch ret.Decision() {
+		case TenantDecisionChangeTenant:
+			return ret
+		case TenantDecisionError:
+			if _, err := ret.DecideTenant(); !errors.Is(err, ErrNoTenantBound) {
+				return ret
+			}
+		case TenantDecisionNoChange:
+		}
+		return &TenantDecisionResultChangeTenant{Tenant: tenant}
+	}
+}
+
+// Require returns a [DecideRequestTenantFunc] that decides [TenantDecisionResultError] with [ErrNoTenantBound]
+// instead of [TenantDecisionResultNoChange], so that the default tenant is never used.
+func Require(fn DecideRequestTenantFunc) DecideRequestTenantFunc {
+	return func(r *http.Request) TenantDecisionResult {
+		ret := fn(r)
+		if ret.Decision() == TenantDecisionNoChange {
+			return &TenantDecisionResultError{Err: ErrNoTenantBound}
+		}
+		return ret
+	}
+}
+
+// MapTenant returns a [DecideRequestTenantFunc] that transforms the tenant decided by the function.
+//
+// The transform is applied only to [TenantDecisionResultChangeTenant] and its error is decided as [TenantDecisionResultError].
+func MapTenant(fn DecideRequestTenantFunc, transform func(Tenant) (Tenant, error)) DecideRequestTenantFunc {
+	return func(r *http.Request) TenantDecisionResult {
+		ret := fn(r)
+		if ret.Decision() != TenantDecisionChangeTenant {
+			return ret
+		}
+		tenant, err := ret.DecideTenant()
+		if err == nil {
+			tenant, err = transform(tenant)
+		}
+		if err != nil {
+			return &TenantDecisionResultError{Err: err}
+		}
+		return &TenantDecisionResultChangeTenant{Tenant: tenant}
+	}
+}
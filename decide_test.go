@@ -0,0 +1,194 @@
+package nagaya_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aereal/nagaya"
+)
+
+var errForbiddenSubdomain = errors.New("forbidden subdomain")
+
+func TestDecideTenantFromSubdomain(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		host         string
+		opts         []nagaya.SubdomainOption
+		wantDecision nagaya.TenantDecision
+		wantTenant   nagaya.Tenant
+		wantErr      error
+	}{
+		{name: "subdomain", host: "acme.example.com", wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "acme"},
+		{name: "uppercase", host: "ACME.example.com", wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "acme"},
+		{name: "with port", host: "acme.example.com:8080", wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "acme"},
+		{name: "nested subdomain", host: "acme.eu.example.com", wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "acme"},
+		{name: "no subdomain", host: "example.com", wantDecision: nagaya.TenantDecisionNoChange},
+		{name: "IP address", host: "127.0.0.1:8080", wantDecision: nagaya.TenantDecisionNoChange},
+		{
+			name:         "excluded",
+			host:         "www.example.com",
+			opts:         []nagaya.SubdomainOption{nagaya.WithExcludedSubdomains("WWW", "api")},
+			wantDecision: nagaya.TenantDecisionNoChange,
+		},
+		{
+			name:         "base domain",
+			host:         "acme.app.example.co.jp",
+			opts:         []nagaya.SubdomainOption{nagaya.WithBaseDomain("app.example.co.jp")},
+			wantDecision: nagaya.TenantDecisionChangeTenant,
+			wantTenant:   "acme",
+		},
+		{
+			name:         "base domain itself",
+			host:         "app.example.co.jp",
+			opts:         []nagaya.SubdomainOption{nagaya.WithBaseDomain("app.example.co.jp")},
+			wantDecision: nagaya.TenantDecisionNoChange,
+		},
+		{
+			name:         "outside of base domain",
+			host:         "acme.example.net",
+			opts:         []nagaya.SubdomainOption{nagaya.WithBaseDomain("example.com")},
+			wantDecision: nagaya.TenantDecisionError,
+			wantErr:      nagaya.ErrNoTenantBound,
+		},
+		{
+			name:         "without port stripping",
+			host:         "acme.localhost:8080",
+			opts:         []nagaya.SubdomainOption{nagaya.WithBaseDomain("localhost:8080"), nagaya.WithPortStripping(false)},
+			wantDecision: nagaya.TenantDecisionChangeTenant,
+			wantTenant:   "acme",
+		},
+		{
+			name: "mapper",
+			host: "acme.example.com",
+			opts: []nagaya.SubdomainOption{nagaya.WithSubdomainMapper(func(subdomain string) (nagaya.Tenant, error) {
+				return nagaya.Tenant("tenant_" + subdomain), nil
+			})},
+			wantDecision: nagaya.TenantDecisionChangeTenant,
+			wantTenant:   "tenant_acme",
+		},
+		{
+			name: "mapper fails",
+			host: "acme.example.com",
+			opts: []nagaya.SubdomainOption{nagaya.WithSubdomainMapper(func(string) (nagaya.Tenant, error) {
+				return "", errForbiddenSubdomain
+			})},
+			wantDecision: nagaya.TenantDecisionError,
+			wantErr:      errForbiddenSubdomain,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tc.host
+			assertTenantDecisionResult(t, nagaya.DecideTenantFromSubdomain(tc.opts...)(r), tc.wantDecision, tc.wantTenant, tc.wantErr)
+		})
+	}
+}
+
+func assertTenantDecisionResult(t *testing.T, ret nagaya.TenantDecisionResult, wantDecision nagaya.TenantDecision, wantTenant nagaya.Tenant, wantErr error) {
+	t.Helper()
+
+	if got := ret.Decision(); got != wantDecision {
+		t.Errorf("Decision():\n\twant: %d\n\t got: %d", wantDecision, got)
+	}
+	tenant, err := ret.DecideTenant()
+	if tenant != wantTenant {
+		t.Errorf("tenant:\n\twant: %s\n\t got: %s", wantTenant, tenant)
+	}
+	switch {
+	case wantErr != nil:
+		if !errors.Is(err, wantErr) {
+			t.Errorf("error:\n\twant: %v\n\t got: %v", wantErr, err)
+		}
+	case wantDecision == nagaya.TenantDecisionNoChange:
+		if !errors.Is(err, nagaya.ErrNoTenantChange) {
+			t.Errorf("error:\n\twant: %v\n\t got: %v", nagaya.ErrNoTenantChange, err)
+		}
+	case err != nil:
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+var errInvalidToken = errors.New("invalid token")
+
+func TestDecideRequestTenantFunc_combinators(t *testing.T) {
+	t.Parallel()
+
+	var (
+		change = func(tenant nagaya.Tenant) nagaya.DecideRequestTenantFunc {
+			return func(*http.Request) nagaya.TenantDecisionResult {
+				return &nagaya.TenantDecisionResultChangeTenant{Tenant: tenant}
+			}
+		}
+		noChange = func(*http.Request) nagaya.TenantDecisionResult { return nagaya.TenantDecisionResultNoChange{} }
+		fail     = func(err error) nagaya.DecideRequestTenantFunc {
+			return func(*http.Request) nagaya.TenantDecisionResult { return &nagaya.TenantDecisionResultError{Err: err} }
+		}
+		prefixed = func(tenant nagaya.Tenant) (nagaya.Tenant, error) { return "tenant_" + tenant, nil }
+		rejected = func(nagaya.Tenant) (nagaya.Tenant, error) { return "", errForbiddenSubdomain }
+	)
+	testCases := []struct {
+		name         string
+		fn           nagaya.DecideRequestTenantFunc
+		wantDecision nagaya.TenantDecision
+		wantTenant   nagaya.Tenant
+		wantErr      error
+	}{
+		{name: "FirstOf/first change wins", fn: nagaya.FirstOf(noChange, fail(nagaya.ErrNoTenantBound), change("a"), change("b")), wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "a"},
+		{name: "FirstOf/error stops", fn: nagaya.FirstOf(noChange, fail(errInvalidToken), change("a")), wantDecision: nagaya.TenantDecisionError, wantErr: errInvalidToken},
+		{name: "FirstOf/no tenant bound", fn: nagaya.FirstOf(noChange, fail(nagaya.ErrNoTenantBound), noChange), wantDecision: nagaya.TenantDecisionError, wantErr: nagaya.ErrNoTenantBound},
+		{name: "FirstOf/no change", fn: nagaya.FirstOf(noChange, noChange), wantDecision: nagaya.TenantDecisionNoChange},
+		{name: "FirstOf/empty", fn: nagaya.FirstOf(), wantDecision: nagaya.TenantDecisionNoChange},
+		{name: "Fallback/change", fn: nagaya.Fallback(change("a"), "default"), wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "a"},
+		{name: "Fallback/no change", fn: nagaya.Fallback(noChange, "default"), wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "default"},
+		{name: "Fallback/no tenant bound", fn: nagaya.Fallback(fail(nagaya.ErrNoTenantBound), "default"), wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "default"},
+		{name: "Fallback/other error", fn: nagaya.Fallback(fail(errInvalidToken), "default"), wantDecision: nagaya.TenantDecisionError, wantErr: errInvalidToken},
+		{name: "Require/no change", fn: nagaya.Require(noChange), wantDecision: nagaya.TenantDecisionError, wantErr: nagaya.ErrNoTenantBound},
+		{name: "Require/change", fn: nagaya.Require(change("a")), wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "a"},
+		{name: "MapTenant/change", fn: nagaya.MapTenant(change("a"), prefixed), wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "tenant_a"},
+		{name: "MapTenant/no change", fn: nagaya.MapTenant(noChange, prefixed), wantDecision: nagaya.TenantDecisionNoChange},
+		{name: "MapTenant/transform fails", fn: nagaya.MapTenant(change("a"), rejected), wantDecision: nagaya.TenantDecisionError, wantErr: errForbiddenSubdomain},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			assertTenantDecisionResult(t, tc.fn(r), tc.wantDecision, tc.wantTenant, tc.wantErr)
+		})
+	}
+}
+
+func TestDecideRequestTenantFunc_chain(t *testing.T) {
+	t.Parallel()
+
+	decide := nagaya.Fallback(nagaya.FirstOf(nagaya.DecideTenantFromSubdomain(), nagaya.HeaderTenant("tenant-id")), "tenant_default")
+	testCases := []struct {
+		name       string
+		host       string
+		header     string
+		wantTenant nagaya.Tenant
+	}{
+		{name: "subdomain", host: "acme.example.com", header: "tenant_1", wantTenant: "acme"},
+		{name: "header", host: "example.com", header: "tenant_1", wantTenant: "tenant_1"},
+		{name: "default", host: "example.com", wantTenant: "tenant_default"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tc.host
+			if tc.header != "" {
+				r.Header.Set("tenant-id", tc.header)
+			}
+			assertTenantDecisionResult(t, decide(r), nagaya.TenantDecisionChangeTenant, tc.wantTenant, nil)
+		})
+	}
+}
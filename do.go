@@ -2,7 +2,10 @@ package nagaya
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"log/slog"
+	"slices"
 )
 
 // Do runs a handler with the database connection that bound for the determined tenant.
@@ -35,12 +38,19 @@ func newDoer[DB DBish, Conn Connish](n *Nagaya[DB, Conn], handler func(context.C
 	if cfg.tenantDecisionRet == nil {
 		cfg.tenantDecisionRet = failedToDetermineTenantResult
 	}
+	logger := cfg.logger
+	if logger == nil {
+		logger = n.logger
+	}
 	return &doer[DB, Conn]{
 		n:                    n,
+		logger:               logger,
 		decisionResult:       cfg.tenantDecisionRet,
 		handler:              handler,
 		idGenerator:          cfg.reqIDGen,
-		bindConnectionOption: cfg.bindConnectionOpts,
+		registry:             cfg.registry,
+		txOptions:            cfg.txOptions,
+		bindConnectionOption: append(slices.Clip(cfg.bindConnectionOpts), &optLogger{logger: logger}),
 	}
 }
 
@@ -48,8 +58,28 @@ type doer[DB DBish, Conn Connish] struct {
 	n                    *Nagaya[DB, Conn]
 	decisionResult       TenantDecisionResult
 	idGenerator          RequestIDGenerator
+	registry             TenantRegistry
+	logger               *slog.Logger
 	handler              func(context.Context) error
 	bindConnectionOption []BindConnectionOption
+	txOptions            *sql.TxOptions
+	// tenant and reqID are recorded as the doer proceeds so that the error handler can tell them.
+	tenant Tenant
+	reqID  string
+	// conn is the connection bound for the tenant if bound is true.
+	conn  Conn
+	bound bool
+}
+
+// boundContext returns the context that conveys the tenant and the request ID decided so far.
+func (d *doer[DB, Conn]) boundContext(ctx context.Context) context.Context {
+	if d.tenant != "" {
+		ctx = WithTenant(ctx, d.tenant)
+	}
+	if d.reqID != "" {
+		ctx = ContextWithRequestID(ctx, d.reqID)
+	}
+	return ctx
 }
 
 func (d *doer[DB, Conn]) do(ctx context.Context) error {
@@ -58,18 +88,54 @@ func (d *doer[DB, Conn]) do(ctx context.Context) error {
 		return d.handler(ctx)
 	}
 	if err != nil {
-		return err
+		return d.fail(ctx, "failed to decide tenant", err)
+	}
+	d.tenant = tenant
+	if d.registry != nil {
+		found, err := d.registry.Exists(ctx, tenant)
+		if err != nil {
+			return d.fail(ctx, "failed to look up tenant", &LookupTenantError{err: err, tenant: tenant})
+		}
+		if !found {
+			return d.fail(ctx, "unknown tenant", &UnknownTenantError{tenant: tenant})
+		}
 	}
 	id, err := d.idGenerator.GenerateID()
 	if err != nil {
-		return &GenerateRequestIDError{err: err}
+		return d.fail(ctx, "failed to generate request ID", &GenerateRequestIDError{err: err})
 	}
-	handlerCtx := ContextWithRequestID(WithTenant(ctx, tenant), id)
+	d.reqID = id
+	handlerCtx := d.boundContext(ctx)
 	conn, err := d.n.BindConnection(handlerCtx, tenant, d.bindConnectionOption...)
 	if err != nil {
+		// BindConnection logs the failure by itself.
 		return err
 	}
-	defer func() { _ = conn.Close() }()
-	defer d.n.ReleaseConnection(id)
+	d.conn, d.bound = conn, true
+	defer func() {
+		_ = d.n.ReleaseReadConnection(handlerCtx, id)
+		// the connection is restored before it is released so that the logger given to BindConnection is used.
+		_ = d.n.RestoreConnection(handlerCtx, conn)
+		d.n.ReleaseConnection(id)
+		_ = conn.Close()
+	}()
 	return d.handler(handlerCtx)
 }
+
+// txBeginner returns the bound connection or the DB if the tenant is not changed.
+func (d *doer[DB, Conn]) txBeginner() txBeginner {
+	if d.bound {
+		return d.conn
+	}
+	return d.n.db
+}
+
+// fail logs the error that occurred before binding the connection and returns it.
+func (d *doer[DB, Conn]) fail(ctx context.Context, msg string, err error) error {
+	attrs := []slog.Attr{slog.Any("error", err)}
+	if d.tenant != "" {
+		attrs = append(attrs, logAttrTenant(d.tenant))
+	}
+	d.logger.LogAttrs(ctx, errorLogLevel(err), msg, attrs...)
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/aereal/nagaya"
@@ -38,6 +39,89 @@ func TestDo(t *testing.T) {
 	}
 }
 
+type recordingSwitcher struct {
+	nagaya.MySQLTenantSwitcher
+	switched []nagaya.Tenant
+	mux      sync.Mutex
+}
+
+func (s *recordingSwitcher) Switch(ctx context.Context, conn nagaya.Execer, tenant nagaya.Tenant) error {
+	s.mux.Lock()
+	s.switched = append(s.switched, tenant)
+	s.mux.Unlock()
+	return s.MySQLTenantSwitcher.Switch(ctx, conn, tenant)
+}
+
+func TestDo_withTenantSwitcher(t *testing.T) {
+	t.Parallel()
+
+	switcher := new(recordingSwitcher)
+	ngy, err := newMySQLNagayaForTesting(nagaya.WithTenantSwitcher(switcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decision := &nagaya.TenantDecisionResultChangeTenant{Tenant: nagaya.Tenant("tenant_3")}
+	dbName, err := nagaya.Yield(t.Context(), ngy, func(ctx context.Context) (string, error) { return getCurrentDBName(ctx, ngy) }, nagaya.WithTenantDecisionResult(decision))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbName != "tenant_3" {
+		t.Errorf("unexpected DB: %s", dbName)
+	}
+	if len(switcher.switched) != 1 || switcher.switched[0] != "tenant_3" {
+		t.Errorf("unexpected switched tenants: %v", switcher.switched)
+	}
+}
+
+func TestDo_restoresConnection(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		switcher *nagaya.MySQLTenantSwitcher
+		wantDB   string
+	}{
+		{
+			name:     "discard the connection",
+			switcher: &nagaya.MySQLTenantSwitcher{},
+			wantDB:   "tenant_default",
+		},
+		{
+			name:     "restore the default tenant",
+			switcher: &nagaya.MySQLTenantSwitcher{DefaultTenant: "tenant_1"},
+			wantDB:   "tenant_1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, err := openMySQLForTesting()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = db.Close() })
+			// the raw DB must reuse the connection that the handler used if it is returned to the pool.
+			db.SetMaxOpenConns(1)
+			db.SetMaxIdleConns(1)
+			ngy := nagaya.NewStd(db, nagaya.WithTenantSwitcher(tc.switcher))
+
+			decision := &nagaya.TenantDecisionResultChangeTenant{Tenant: nagaya.Tenant("tenant_2")}
+			if err := nagaya.Do(t.Context(), ngy, func(context.Context) error { return nil }, nagaya.WithTenantDecisionResult(decision)); err != nil {
+				t.Fatal(err)
+			}
+			var dbName string
+			if err := db.QueryRowContext(t.Context(), `select database()`).Scan(&dbName); err != nil {
+				t.Fatal(err)
+			}
+			if dbName != tc.wantDB {
+				t.Errorf("the raw DB observes unexpected database:\n\twant: %s\n\t got: %s", tc.wantDB, dbName)
+			}
+		})
+	}
+}
+
 func TestYield(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,80 @@
+package nagaya
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Each runs the handler against every tenant with the database connection bound for each tenant.
+//
+// The failures of the tenants are wrapped by [TenantError] and joined by [errors.Join].
+// The handler is called for all tenants even if some of them fail.
+func Each[DB DBish, Conn Connish](ctx context.Context, n *Nagaya[DB, Conn], tenants []Tenant, handler func(context.Context) error, opts ...EachOption) error {
+	yielder := func(ctx context.Context) (struct{}, error) { return struct{}{}, handler(ctx) }
+	_, err := Map(ctx, n, tenants, yielder, opts...)
+	return err
+}
+
+// Map is the counterpart of [Yield] for [Each].
+//
+// It returns the values yielded for the tenants that succeeded even if some of tenants fail.
+func Map[V any, DB DBish, Conn Connish](ctx context.Context, n *Nagaya[DB, Conn], tenants []Tenant, yielder func(context.Context) (V, error), opts ...EachOption) (_ map[Tenant]V, err error) {
+	cfg := &eachConfig{concurrency: 1}
+	for _, o := range opts {
+		o.applyEachOption(cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+	ctx, span := n.tracer.Start(ctx, "Nagaya.Each", trace.WithAttributes(attribute.Int("nagaya.tenants_count", len(tenants))))
+	defer func() { finishSpan(span, err) }()
+
+	var (
+		values = make(map[Tenant]V, len(tenants))
+		errs   = make([]error, len(tenants))
+		mux    sync.Mutex
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, cfg.concurrency)
+	)
+	for i, tenant := range tenants {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = &TenantError{err: ctx.Err(), tenant: tenant}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			v, err := yieldTenant(ctx, n, tenant, yielder, cfg)
+			if err != nil {
+				errs[i] = &TenantError{err: err, tenant: tenant}
+				return
+			}
+			mux.Lock()
+			values[tenant] = v
+			mux.Unlock()
+		}()
+	}
+	wg.Wait()
+	return values, errors.Join(errs...)
+}
+
+func yieldTenant[V any, DB DBish, Conn Connish](ctx context.Context, n *Nagaya[DB, Conn], tenant Tenant, yielder func(context.Context) (V, error), cfg *eachConfig) (_ V, err error) {
+	ctx, span := n.tracer.Start(ctx, "Nagaya.Each.Tenant", trace.WithAttributes(attrTenant(tenant)))
+	defer func() { finishSpan(span, err) }()
+
+	if cfg.tenantTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.tenantTimeout)
+		defer cancel()
+	}
+	opts := append(slices.Clone(cfg.doOpts), WithTenantDecisionResult(&TenantDecisionResultChangeTenant{Tenant: tenant}))
+	return Yield(ctx, n, yielder, opts...)
+}
@@ -0,0 +1,71 @@
+package nagaya_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+var errTenant2 = errors.New("tenant_2 failed")
+
+func TestMap(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newMySQLNagayaForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants := []nagaya.Tenant{"tenant_1", "tenant_2", "tenant_3"}
+	yielder := func(ctx context.Context) (string, error) { return getCurrentDBName(ctx, ngy) }
+	got, err := nagaya.Map(t.Context(), ngy, tenants, yielder, nagaya.WithConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[nagaya.Tenant]string{"tenant_1": "tenant_1", "tenant_2": "tenant_2", "tenant_3": "tenant_3"}
+	if !maps.Equal(got, want) {
+		t.Errorf("Map():\n\twant: %v\n\t got: %v", want, got)
+	}
+}
+
+func TestEach(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newMySQLNagayaForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants := []nagaya.Tenant{"tenant_1", "tenant_2", "tenant_3"}
+	handler := func(ctx context.Context) error {
+		dbName, err := getCurrentDBName(ctx, ngy)
+		if err != nil {
+			return err
+		}
+		switch dbName {
+		case "tenant_2":
+			return errTenant2
+		case "tenant_3":
+			<-ctx.Done()
+			return ctx.Err()
+		default:
+			return nil
+		}
+	}
+	err = nagaya.Each(t.Context(), ngy, tenants, handler, nagaya.WithConcurrency(3), nagaya.WithTenantTimeout(time.Millisecond*100))
+	if !errors.Is(err, errTenant2) {
+		t.Errorf("expected the error of tenant_2 but got: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the timeout of tenant_3 but got: %v", err)
+	}
+	var tenantErr *nagaya.TenantError
+	if !errors.As(err, &tenantErr) {
+		t.Fatalf("expected TenantError but got: %T", err)
+	}
+	if tenant := tenantErr.Tenant(); tenant != "tenant_2" && tenant != "tenant_3" {
+		t.Errorf("unexpected failed tenant: %s", tenant)
+	}
+}
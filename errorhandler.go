@@ -0,0 +1,173 @@
+package nagaya
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultRetryAfter = time.Second
+
+// ErrorStatusFunc is a function that decides the HTTP status code for the error.
+//
+// It returns false if it does not know the error.
+type ErrorStatusFunc func(err error) (status int, ok bool)
+
+// ErrorStatusIs returns an [ErrorStatusFunc] that maps the errors that match the target by [errors.Is] to the status.
+func ErrorStatusIs(target error, status int) ErrorStatusFunc {
+	return func(err error) (int, bool) {
+		if errors.Is(err, target) {
+			return status, true
+		}
+		return 0, false
+	}
+}
+
+// ErrorStatusAs returns an [ErrorStatusFunc] that maps the errors that match the type E by [errors.As] to the status.
+func ErrorStatusAs[E error](status int) ErrorStatusFunc {
+	return func(err error) (int, bool) {
+		var target E
+		if errors.As(err, &target) {
+			return status, true
+		}
+		return 0, false
+	}
+}
+
+// defaultErrorStatusFuncs are the mappings for the errors that this package returns.
+var defaultErrorStatusFuncs = []ErrorStatusFunc{
+	ErrorStatusAs[*TokenVerificationError](http.StatusUnauthorized),
+	ErrorStatusIs(ErrNoTenantBound, http.StatusBadRequest),
+	ErrorStatusIs(ErrInvalidTenant, http.StatusBadRequest),
+	ErrorStatusIs(ErrNoConnectionBound, http.StatusBadRequest),
+	ErrorStatusAs[*UnknownTenantError](http.StatusNotFound),
+	ErrorStatusIs(ErrUnknownHost, http.StatusNotFound),
+	func(err error) (int, bool) {
+		var changeErr *ChangeTenantError
+		if errors.As(err, &changeErr) && errors.Is(changeErr, context.DeadlineExceeded) {
+			return http.StatusServiceUnavailable, true
+		}
+		return 0, false
+	},
+	ErrorStatusAs[*ObtainConnectionError](http.StatusServiceUnavailable),
+	ErrorStatusIs(ErrTenantRelocating, http.StatusServiceUnavailable),
+}
+
+// errorStatusMapper decides the HTTP status code by the user-defined mappings and then the default ones.
+type errorStatusMapper struct {
+	funcs      []ErrorStatusFunc
+	retryAfter time.Duration
+	redact     bool
+}
+
+func newErrorStatusMapper(cfg *errorHandlerConfig) *errorStatusMapper {
+	retryAfter := cfg.retryAfter
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+	funcs := make([]ErrorStatusFunc, 0, len(cfg.statusFuncs)+len(defaultErrorStatusFuncs))
+	funcs = append(funcs, cfg.statusFuncs...)
+	funcs = append(funcs, defaultErrorStatusFuncs...)
+	return &errorStatusMapper{funcs: funcs, retryAfter: retryAfter, redact: cfg.redact}
+}
+
+func (m *errorStatusMapper) status(err error) int {
+	for _, fn := range m.funcs {
+		if status, ok := fn(err); ok {
+			return status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// detail returns the message of the error that can be exposed to the clients.
+//
+// It returns false if the message is redacted.
+func (m *errorStatusMapper) detail(err error, status int) (string, bool) {
+	if m.redact && status >= http.StatusInternalServerError {
+		return "", false
+	}
+	return err.Error(), true
+}
+
+// writeHeader sends the response headers common to the error responses.
+func (m *errorStatusMapper) writeHeader(w http.ResponseWriter, contentType string, status int) {
+	w.Header().Set("content-type", contentType)
+	w.Header().Set("content-security-policy", "default-src 'none'")
+	w.Header().Set("x-content-type-options", "nosniff")
+	w.Header().Set("x-frame-options", "DENY")
+	switch status {
+	case http.StatusUnauthorized:
+		w.Header().Set("www-authenticate", "Bearer")
+	case http.StatusServiceUnavailable:
+		w.Header().Set("retry-after", strconv.Itoa(max(1, int(m.retryAfter.Round(time.Second)/time.Second))))
+	}
+	w.WriteHeader(status)
+}
+
+// JSONErrorHandler returns an [ErrorHandler] that responds the error as a JSON object like {"error": "..."}.
+//
+// It is used by [Middleware] by default.
+// The status code is decided by the mappings given by [WithErrorStatus] and then the default mappings:
+// missing or invalid tenants are 400, unverified tokens are 401, unknown tenants are 404,
+// and timeouts of the tenant switch and failures of obtaining a connection are 503 with Retry-After.
+//
+// If [WithRedactedErrors] is given, the messages of server errors are replaced with the status text.
+func JSONErrorHandler(opts ...ErrorHandlerOption) ErrorHandler {
+	mapper := newErrorStatusMapper(newErrorHandlerConfig(opts))
+	return func(w http.ResponseWriter, _ *http.Request, err error) {
+		status := mapper.status(err)
+		msg, ok := mapper.detail(err, status)
+		if !ok {
+			msg = http.StatusText(status)
+		}
+		mapper.writeHeader(w, "application/json", status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": msg}) //nolint:errcheck,errchkjson
+	}
+}
+
+// ProblemDetails is the response body of [ProblemJSONErrorHandler] defined by RFC 9457.
+type ProblemDetails struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Tenant    Tenant `json:"tenant,omitempty"`
+}
+
+// ProblemJSONErrorHandler returns an [ErrorHandler] that responds the error as an RFC 9457 problem details object
+// with the content type application/problem+json.
+//
+// The type is "about:blank" and the title is the status text, so the status code and the detail tell what happened.
+// The status code is decided in the same manner as [JSONErrorHandler].
+// The request ID and the tenant are included as the extension members if they have been decided before the error.
+//
+// If [WithRedactedErrors] is given, the detail of server errors is omitted.
+func ProblemJSONErrorHandler(opts ...ErrorHandlerOption) ErrorHandler {
+	mapper := newErrorStatusMapper(newErrorHandlerConfig(opts))
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		status := mapper.status(err)
+		problem := &ProblemDetails{
+			Type:   "about:blank",
+			Title:  http.StatusText(status),
+			Status: status,
+		}
+		problem.Detail, _ = mapper.detail(err, status)
+		problem.RequestID, _ = RequestIDFromContext(r.Context())
+		problem.Tenant, _ = TenantFromContext(r.Context())
+		mapper.writeHeader(w, "application/problem+json", status)
+		_ = json.NewEncoder(w).Encode(problem) //nolint:errcheck,errchkjson
+	}
+}
+
+func newErrorHandlerConfig(opts []ErrorHandlerOption) *errorHandlerConfig {
+	cfg := new(errorHandlerConfig)
+	for _, o := range opts {
+		o.applyErrorHandlerOption(cfg)
+	}
+	return cfg
+}
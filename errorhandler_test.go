@@ -0,0 +1,239 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+type quotaExceededError struct{}
+
+func (quotaExceededError) Error() string { return "quota exceeded" }
+
+var errMaintenance = errors.New("under maintenance")
+
+func TestJSONErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		opts           []nagaya.ErrorHandlerOption
+		err            error
+		wantStatus     int
+		wantRetryAfter string
+		wantAuthn      string
+		wantMessage    string
+	}{
+		{name: "no tenant bound", err: nagaya.ErrNoTenantBound, wantStatus: http.StatusBadRequest},
+		{name: "invalid tenant", err: nagaya.Tenant("x;y").Validate(), wantStatus: http.StatusBadRequest},
+		{name: "unverified token", err: decideTenantError(t, nagaya.DecideTenantFromJWT()), wantStatus: http.StatusUnauthorized, wantAuthn: "Bearer"},
+		{name: "unknown tenant", err: unknownTenantError(t), wantStatus: http.StatusNotFound},
+		{name: "unknown host", err: nagaya.ErrUnknownHost, wantStatus: http.StatusNotFound},
+		{name: "tenant switch timeout", err: changeTenantError(t, context.DeadlineExceeded), wantStatus: http.StatusServiceUnavailable, wantRetryAfter: "1"},
+		{name: "tenant switch failure", err: changeTenantError(t, errMaintenance), wantStatus: http.StatusInternalServerError},
+		{
+			name:           "retry after",
+			opts:           []nagaya.ErrorHandlerOption{nagaya.WithRetryAfter(time.Second * 30)},
+			err:            changeTenantError(t, context.DeadlineExceeded),
+			wantStatus:     http.StatusServiceUnavailable,
+			wantRetryAfter: "30",
+		},
+		{name: "unknown error", err: errMaintenance, wantStatus: http.StatusInternalServerError},
+		{
+			name:        "redacted server error",
+			opts:        []nagaya.ErrorHandlerOption{nagaya.WithRedactedErrors()},
+			err:         changeTenantError(t, errUnknownDatabase),
+			wantStatus:  http.StatusInternalServerError,
+			wantMessage: "Internal Server Error",
+		},
+		{
+			name:       "redaction keeps client errors",
+			opts:       []nagaya.ErrorHandlerOption{nagaya.WithRedactedErrors()},
+			err:        nagaya.ErrNoTenantBound,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "user-defined sentinel",
+			opts:       []nagaya.ErrorHandlerOption{nagaya.WithErrorStatus(nagaya.ErrorStatusIs(errMaintenance, http.StatusServiceUnavailable))},
+			err:        fmt.Errorf("wrapped: %w", errMaintenance),
+			wantStatus: http.StatusServiceUnavailable, wantRetryAfter: "1",
+		},
+		{
+			name:       "user-defined type",
+			opts:       []nagaya.ErrorHandlerOption{nagaya.WithErrorStatus(nagaya.ErrorStatusAs[quotaExceededError](http.StatusTooManyRequests))},
+			err:        quotaExceededError{},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "user-defined mapping overrides default",
+			opts:       []nagaya.ErrorHandlerOption{nagaya.WithErrorStatus(nagaya.ErrorStatusIs(nagaya.ErrNoTenantBound, http.StatusForbidden))},
+			err:        nagaya.ErrNoTenantBound,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			nagaya.JSONErrorHandler(tc.opts...)(w, httptest.NewRequest(http.MethodGet, "/", nil), tc.err)
+			resp := w.Result()
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status:\n\twant: %d\n\t got: %d", tc.wantStatus, resp.StatusCode)
+			}
+			if got := resp.Header.Get("retry-after"); got != tc.wantRetryAfter {
+				t.Errorf("Retry-After:\n\twant: %q\n\t got: %q", tc.wantRetryAfter, got)
+			}
+			if got := resp.Header.Get("www-authenticate"); got != tc.wantAuthn {
+				t.Errorf("WWW-Authenticate:\n\twant: %q\n\t got: %q", tc.wantAuthn, got)
+			}
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			wantMessage := tc.wantMessage
+			if wantMessage == "" {
+				wantMessage = tc.err.Error()
+			}
+			if body.Error != wantMessage {
+				t.Errorf("error message:\n\twant: %q\n\t got: %q", wantMessage, body.Error)
+			}
+		})
+	}
+}
+
+func decideTenantError(t *testing.T, fn nagaya.DecideRequestTenantFunc) error {
+	t.Helper()
+
+	_, err := fn(httptest.NewRequest(http.MethodGet, "/", nil)).DecideTenant()
+	if err == nil {
+		t.Fatal("expected the decision fails")
+	}
+	return err
+}
+
+func unknownTenantError(t *testing.T) error {
+	t.Helper()
+
+	_, err := nagaya.NewInMemoryTenantRegistry().Lookup(t.Context(), "tenant_1")
+	if err == nil {
+		t.Fatal("expected the lookup fails")
+	}
+	return err
+}
+
+type failingSwitcher struct {
+	nagaya.MySQLTenantSwitcher
+	err error
+}
+
+func (s *failingSwitcher) Switch(context.Context, nagaya.Execer, nagaya.Tenant) error { return s.err }
+
+func changeTenantError(t *testing.T, cause error) error {
+	t.Helper()
+
+	db := sql.OpenDB(nagaya.NewConnector(stubConnector{}, nagaya.WithTenantSwitcher(&failingSwitcher{err: cause})))
+	t.Cleanup(func() { _ = db.Close() })
+	_, err := db.ExecContext(nagaya.WithTenant(t.Context(), "tenant_1"), "select 1")
+	var changeErr *nagaya.ChangeTenantError
+	if !errors.As(err, &changeErr) {
+		t.Fatalf("expected ChangeTenantError but got %v", err)
+	}
+	return err
+}
+
+func TestProblemJSONErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		opts        []nagaya.ErrorHandlerOption
+		switchErr   error
+		registry    nagaya.TenantRegistry
+		tenant      string
+		wantProblem *nagaya.ProblemDetails
+	}{
+		{
+			name:        "no tenant",
+			wantProblem: &nagaya.ProblemDetails{Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest, Detail: nagaya.ErrNoTenantBound.Error()},
+		},
+		{
+			name:        "unknown tenant",
+			registry:    nagaya.NewInMemoryTenantRegistry(),
+			tenant:      "tenant_1",
+			wantProblem: &nagaya.ProblemDetails{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound, Detail: "unknown tenant: tenant_1", Tenant: "tenant_1"},
+		},
+		{
+			name:        "switch failure",
+			switchErr:   errUnknownDatabase,
+			tenant:      "tenant_1",
+			wantProblem: &nagaya.ProblemDetails{Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError, Detail: "failed to change tenant to tenant_1: " + errUnknownDatabase.Error(), RequestID: "req_1", Tenant: "tenant_1"},
+		},
+		{
+			name:        "redacted",
+			opts:        []nagaya.ErrorHandlerOption{nagaya.WithRedactedErrors()},
+			switchErr:   errUnknownDatabase,
+			tenant:      "tenant_1",
+			wantProblem: &nagaya.ProblemDetails{Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError, RequestID: "req_1", Tenant: "tenant_1"},
+		},
+		{
+			name:        "client errors are not redacted",
+			opts:        []nagaya.ErrorHandlerOption{nagaya.WithRedactedErrors()},
+			wantProblem: &nagaya.ProblemDetails{Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest, Detail: nagaya.ErrNoTenantBound.Error()},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db := sql.OpenDB(stubConnector{})
+			t.Cleanup(func() { _ = db.Close() })
+			ngy := nagaya.NewStd(db, nagaya.WithTenantSwitcher(&failingSwitcher{err: tc.switchErr}))
+			opts := []nagaya.MiddlewareOption{
+				nagaya.DecideTenantFromHeader("tenant-id"),
+				nagaya.WithRequestIDGenerator(nagaya.RequestIDGeneratorFunc(func() (string, error) { return "req_1", nil })),
+				nagaya.WithErrorHandler(nagaya.ProblemJSONErrorHandler(tc.opts...)),
+			}
+			if tc.registry != nil {
+				opts = append(opts, nagaya.WithTenantRegistry(tc.registry))
+			}
+			handler := nagaya.Middleware(ngy, opts...)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.tenant != "" {
+				req.Header.Set("tenant-id", tc.tenant)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantProblem.Status {
+				t.Errorf("status:\n\twant: %d\n\t got: %d", tc.wantProblem.Status, resp.StatusCode)
+			}
+			if got := resp.Header.Get("content-type"); got != "application/problem+json" {
+				t.Errorf("Content-Type: %q", got)
+			}
+			got := new(nagaya.ProblemDetails)
+			if err := json.NewDecoder(resp.Body).Decode(got); err != nil {
+				t.Fatal(err)
+			}
+			if *got != *tc.wantProblem {
+				t.Errorf("problem details:\n\twant: %#v\n\t got: %#v", tc.wantProblem, got)
+			}
+		})
+	}
+}
+
+var errUnknownDatabase = errors.New("Error 1049 (42000): Unknown database 'tenant_1'")
@@ -10,8 +10,34 @@ var (
 	ErrNoTenantBound = errors.New("no tenant bound for the context")
 	// ErrNoConnectionBound is an error represents no DB connection obtained for the context.
 	ErrNoConnectionBound = errors.New("no DB connection bound for the context")
+	// ErrNoTxBound is an error represents no transaction begun for the context.
+	ErrNoTxBound = errors.New("no transaction bound for the context")
 	// ErrNoTenantChange indicates the nagaya no need to change tenant.
 	ErrNoTenantChange = errors.New("no tenant change")
+	// ErrInvalidTenant indicates the tenant is not acceptable.
+	ErrInvalidTenant = errors.New("invalid tenant")
+	// ErrNoDefaultTenant indicates the connection cannot be restored because no default tenant is configured.
+	ErrNoDefaultTenant = errors.New("no default tenant configured")
+	// ErrProvisioningUnsupported indicates the TenantSwitcher does not implement TenantProvisioner.
+	ErrProvisioningUnsupported = errors.New("the tenant switcher does not support provisioning")
+	// ErrUnknownHost indicates no tenant owns the request host.
+	ErrUnknownHost = errors.New("unknown host")
+	// ErrNoBearerToken indicates the request has no bearer token.
+	ErrNoBearerToken = errors.New("no bearer token")
+	// ErrNoTenantClaim indicates the token has no tenant claim.
+	ErrNoTenantClaim = errors.New("no tenant claim in the token")
+	// ErrNoVerificationKey indicates no key can verify the token.
+	ErrNoVerificationKey = errors.New("no key to verify the token")
+	// ErrNoShard indicates the ShardResolver does not know the shard of the tenant.
+	ErrNoShard = errors.New("no shard for the tenant")
+	// ErrTenantRelocating indicates the tenant is being relocated to another shard.
+	ErrTenantRelocating = errors.New("tenant is being relocated")
+	// ErrRelocationUnsupported indicates the Nagaya cannot relocate the tenant.
+	//
+	// The shards must be configured, the TenantSwitcher must implement TenantRelocator and the ShardResolver must implement ShardUpdater.
+	ErrRelocationUnsupported = errors.New("the tenant relocation is not supported")
+	// ErrVolatileShardUpdater indicates the source of the relocation cannot be dropped because the ShardUpdater does not persist the shards.
+	ErrVolatileShardUpdater = errors.New("the shard updater does not persist the shards")
 )
 
 // ObtainConnectionError is an error type represents the failure of obtaining DB connection.
@@ -44,6 +70,160 @@ func (e *ChangeTenantError) Unwrap() error {
 // Tenant returns a tenant to be switched.
 func (e *ChangeTenantError) Tenant() Tenant { return e.tenant }
 
+// UnknownTenantError is an error type represents the tenant is not registered in the [TenantRegistry].
+//
+// It is also returned when the database does not know the tenant to be switched to.
+type UnknownTenantError struct {
+	err    error
+	tenant Tenant
+}
+
+func (e *UnknownTenantError) Error() string {
+	return fmt.Sprintf("unknown tenant: %s", e.tenant)
+}
+
+func (e *UnknownTenantError) Unwrap() error {
+	return e.err
+}
+
+// Tenant returns a tenant that is not registered.
+func (e *UnknownTenantError) Tenant() Tenant { return e.tenant }
+
+// TenantAlreadyExistsError is an error type represents the tenant to be created already exists.
+type TenantAlreadyExistsError struct {
+	tenant Tenant
+}
+
+func (e *TenantAlreadyExistsError) Error() string {
+	return fmt.Sprintf("tenant already exists: %s", e.tenant)
+}
+
+// Tenant returns a tenant that already exists.
+func (e *TenantAlreadyExistsError) Tenant() Tenant { return e.tenant }
+
+// ApplySchemaTemplateError is an error type represents the failure of applying the schema template to the created tenant.
+type ApplySchemaTemplateError struct {
+	err    error
+	tenant Tenant
+}
+
+func (e *ApplySchemaTemplateError) Error() string {
+	return fmt.Sprintf("failed to apply schema template to tenant %s: %s", e.tenant, e.err)
+}
+
+func (e *ApplySchemaTemplateError) Unwrap() error { return e.err }
+
+// Tenant returns a tenant that the schema template is applied to.
+func (e *ApplySchemaTemplateError) Tenant() Tenant { return e.tenant }
+
+// InvalidSpecError is an error type represents the [TenantSpec] contains a value that cannot be embedded in DDL.
+type InvalidSpecError struct {
+	value string
+}
+
+func (e *InvalidSpecError) Error() string {
+	return fmt.Sprintf("invalid tenant spec value: %q", e.value)
+}
+
+// InvalidMigrationError is an error type represents the migration file cannot be used.
+type InvalidMigrationError struct {
+	name   string
+	reason string
+}
+
+func (e *InvalidMigrationError) Error() string {
+	return fmt.Sprintf("invalid migration %s: %s", e.name, e.reason)
+}
+
+// MigrateTenantError is an error type represents the failure of applying the migrations to the tenant.
+type MigrateTenantError struct {
+	err     error
+	tenant  Tenant
+	version int64
+}
+
+func (e *MigrateTenantError) Error() string {
+	if e.version == 0 {
+		return fmt.Sprintf("failed to migrate tenant %s: %s", e.tenant, e.err)
+	}
+	return fmt.Sprintf("failed to migrate tenant %s to version %d: %s", e.tenant, e.version, e.err)
+}
+
+func (e *MigrateTenantError) Unwrap() error { return e.err }
+
+// Tenant returns a tenant that failed to migrate.
+func (e *MigrateTenantError) Tenant() Tenant { return e.tenant }
+
+// Version returns a version of the migration that failed.
+//
+// It returns zero if the failure is not caused by a specific migration.
+func (e *MigrateTenantError) Version() int64 { return e.version }
+
+// TokenVerificationError is an error type represents the failure of verifying the token that conveys the tenant.
+type TokenVerificationError struct {
+	err error
+}
+
+func (e *TokenVerificationError) Error() string {
+	return fmt.Sprintf("failed to verify token: %s", e.err)
+}
+
+func (e *TokenVerificationError) Unwrap() error { return e.err }
+
+// InvalidJWKError is an error type represents the JSON Web Key cannot be used.
+type InvalidJWKError struct {
+	kid    string
+	reason string
+}
+
+func (e *InvalidJWKError) Error() string {
+	if e.kid == "" {
+		return fmt.Sprintf("invalid JWK: %s", e.reason)
+	}
+	return fmt.Sprintf("invalid JWK %s: %s", e.kid, e.reason)
+}
+
+// LookupTenantError is an error type represents the failure of looking up the tenant in the [TenantRegistry].
+type LookupTenantError struct {
+	err    error
+	tenant Tenant
+}
+
+func (e *LookupTenantError) Error() string {
+	return fmt.Sprintf("failed to look up tenant %s: %s", e.tenant, e.err)
+}
+
+func (e *LookupTenantError) Unwrap() error { return e.err }
+
+// Tenant returns a tenant to be looked up.
+func (e *LookupTenantError) Tenant() Tenant { return e.tenant }
+
+// TenantError is an error type represents the failure of a tenant processed by [Each] or [Map].
+type TenantError struct {
+	err    error
+	tenant Tenant
+}
+
+func (e *TenantError) Error() string {
+	return fmt.Sprintf("tenant %s: %s", e.tenant, e.err)
+}
+
+func (e *TenantError) Unwrap() error { return e.err }
+
+// Tenant returns a tenant that failed.
+func (e *TenantError) Tenant() Tenant { return e.tenant }
+
+// ResetTenantError is an error type represents the failure of restoring the connection to the default tenant.
+type ResetTenantError struct {
+	err error
+}
+
+func (e *ResetTenantError) Error() string {
+	return fmt.Sprintf("failed to reset tenant: %s", e.err)
+}
+
+func (e *ResetTenantError) Unwrap() error { return e.err }
+
 // GenerateRequestIDError is an error type represents the failure of generating ID of the current request.
 type GenerateRequestIDError struct {
 	err error
@@ -54,3 +234,87 @@ func (e *GenerateRequestIDError) Error() string {
 }
 
 func (e *GenerateRequestIDError) Unwrap() error { return e.err }
+
+// InvalidTenantError is an error type represents the tenant does not satisfy the [TenantRule].
+type InvalidTenantError struct {
+	tenant Tenant
+	reason string
+}
+
+func (e *InvalidTenantError) Error() string {
+	return fmt.Sprintf("invalid tenant %q: %s", string(e.tenant), e.reason)
+}
+
+func (e *InvalidTenantError) Unwrap() error { return ErrInvalidTenant }
+
+// Tenant returns a tenant that is rejected.
+func (e *InvalidTenantError) Tenant() Tenant { return e.tenant }
+
+// HookError is an error type represents the failure of the hook.
+type HookError struct {
+	err    error
+	point  HookPoint
+	tenant Tenant
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook failed for tenant %s: %s", e.point, e.tenant, e.err)
+}
+
+func (e *HookError) Unwrap() error { return e.err }
+
+// Point returns the point that the hook runs at.
+func (e *HookError) Point() HookPoint { return e.point }
+
+// Tenant returns a tenant that the connection is bound for.
+func (e *HookError) Tenant() Tenant { return e.tenant }
+
+// ResolveShardError is an error type represents the failure of deciding the shard of the tenant.
+type ResolveShardError struct {
+	err    error
+	tenant Tenant
+}
+
+func (e *ResolveShardError) Error() string {
+	return fmt.Sprintf("failed to resolve shard of tenant %s: %s", e.tenant, e.err)
+}
+
+func (e *ResolveShardError) Unwrap() error { return e.err }
+
+// Tenant returns a tenant whose shard is not resolved.
+func (e *ResolveShardError) Tenant() Tenant { return e.tenant }
+
+// UnknownShardError is an error type represents the [ShardResolver] returns a shard that is not configured.
+type UnknownShardError struct {
+	shard string
+}
+
+func (e *UnknownShardError) Error() string {
+	return fmt.Sprintf("unknown shard: %s", e.shard)
+}
+
+// Shard returns a name of the shard that is not configured.
+func (e *UnknownShardError) Shard() string { return e.shard }
+
+// RelocateTenantError is an error type represents the failure of relocating the tenant to another shard.
+type RelocateTenantError struct {
+	err    error
+	tenant Tenant
+	from   string
+	to     string
+}
+
+func (e *RelocateTenantError) Error() string {
+	return fmt.Sprintf("failed to relocate tenant %s from shard %s to %s: %s", e.tenant, e.from, e.to, e.err)
+}
+
+func (e *RelocateTenantError) Unwrap() error { return e.err }
+
+// Tenant returns a tenant that failed to be relocated.
+func (e *RelocateTenantError) Tenant() Tenant { return e.tenant }
+
+// From returns a name of the shard that the tenant is relocated from.
+func (e *RelocateTenantError) From() string { return e.from }
+
+// To returns a name of the shard that the tenant is relocated to.
+func (e *RelocateTenantError) To() string { return e.to }
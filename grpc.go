@@ -0,0 +1,130 @@
+package nagaya
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DecideRPCTenantFunc is a function that decides the tenant from the incoming context of the RPC.
+type DecideRPCTenantFunc func(ctx context.Context) TenantDecisionResult
+
+func failsToDetermineRPCTenant(_ context.Context) TenantDecisionResult {
+	return failedToDetermineTenantResult
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that determines target tenant and obtain the database connection against the tenant.
+//
+// It is the gRPC counterpart of [Middleware].
+// The handler must get the obtained connection via [Nagaya.ObtainConnection] method and use it to access the database.
+func UnaryServerInterceptor[DB DBish, Conn Connish](n *Nagaya[DB, Conn], opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	cfg := newInterceptorConfig(opts...)
+	tracer := getTracer(cfg.tp)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		ctx, span := tracer.Start(ctx, "Nagaya.UnaryServerInterceptor", trace.WithSpanKind(trace.SpanKindServer))
+		var handled bool
+		err = newDoer(n, func(ctx context.Context) error {
+			handled = true
+			finishSpan(span, nil)
+			var handlerErr error
+			resp, handlerErr = handler(ctx, req)
+			return handlerErr
+		}, cfg.doOptions(ctx)...).do(ctx)
+		if err != nil && !handled {
+			finishSpan(span, err)
+			return nil, rpcStatusError(err)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor for the streaming RPC that works like [UnaryServerInterceptor].
+func StreamServerInterceptor[DB DBish, Conn Connish](n *Nagaya[DB, Conn], opts ...InterceptorOption) grpc.StreamServerInterceptor {
+	cfg := newInterceptorConfig(opts...)
+	tracer := getTracer(cfg.tp)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := tracer.Start(ss.Context(), "Nagaya.StreamServerInterceptor", trace.WithSpanKind(trace.SpanKindServer))
+		var handled bool
+		err := newDoer(n, func(ctx context.Context) error {
+			handled = true
+			finishSpan(span, nil)
+			return handler(srv, &tenantServerStream{ServerStream: ss, ctx: ctx})
+		}, cfg.doOptions(ctx)...).do(ctx)
+		if err != nil && !handled {
+			finishSpan(span, err)
+			return rpcStatusError(err)
+		}
+		return err
+	}
+}
+
+func newInterceptorConfig(opts ...InterceptorOption) *interceptorConfig {
+	cfg := &interceptorConfig{bindConnectionCfg: new(bindConnectionConfig)}
+	for _, o := range opts {
+		o.applyInterceptorOption(cfg)
+	}
+	if cfg.decideTenant == nil {
+		cfg.decideTenant = failsToDetermineRPCTenant
+	}
+	return cfg
+}
+
+func (cfg *interceptorConfig) doOptions(ctx context.Context) []DoOption {
+	opts := []DoOption{&optTenantDecisionResult{cfg.decideTenant(ctx)}, &optTenantRegistry{cfg.registry}}
+	if cfg.reqIDGen != nil {
+		opts = append(opts, WithRequestIDGenerator(cfg.reqIDGen))
+	}
+	if timeout := cfg.bindConnectionCfg.changeTenantTimeout; timeout != 0 {
+		opts = append(opts, WithTimeout(timeout))
+	}
+	return opts
+}
+
+type tenantServerStream struct {
+	grpc.ServerStream
+	ctx context.Context //nolint:containedctx
+}
+
+func (s *tenantServerStream) Context() context.Context { return s.ctx }
+
+// DecideTenantFromMetadata tells the interceptor to use given metadata value to decide the tenant.
+//
+// It is the gRPC counterpart of [DecideTenantFromHeader].
+func DecideTenantFromMetadata(key string) InterceptorOption {
+	return &optDecideRPCTenantFn{
+		fn: func(ctx context.Context) TenantDecisionResult {
+			md, _ := metadata.FromIncomingContext(ctx)
+			values := md.Get(key)
+			if len(values) == 0 || values[0] == "" {
+				return &TenantDecisionResultError{Err: ErrNoTenantBound}
+			}
+			return &TenantDecisionResultChangeTenant{Tenant: Tenant(values[0])}
+		},
+	}
+}
+
+// rpcStatusError maps the error occurred while binding the connection to the gRPC status.
+func rpcStatusError(err error) error {
+	var (
+		unknownErr *UnknownTenantError
+		code       = codes.Internal
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	case errors.Is(err, ErrNoTenantBound), errors.Is(err, ErrInvalidTenant), errors.Is(err, ErrNoConnectionBound):
+		code = codes.InvalidArgument
+	case errors.As(err, &unknownErr):
+		code = codes.NotFound
+	case errors.As(err, new(*ObtainConnectionError)), errors.As(err, new(*ChangeTenantError)), errors.Is(err, ErrTenantRelocating):
+		code = codes.Unavailable
+	}
+	return status.Error(code, err.Error())
+}
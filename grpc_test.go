@@ -0,0 +1,134 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"testing"
+
+	"github.com/aereal/nagaya"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type tenantHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	ngy     *nagaya.Nagaya[*sql.DB, *sql.Conn]
+	dbNames chan string
+}
+
+func (s *tenantHealthServer) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	dbName, err := getCurrentDBName(ctx, s.ngy)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.dbNames <- dbName
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *tenantHealthServer) Watch(_ *grpc_health_v1.HealthCheckRequest, stream grpc.ServerStreamingServer[grpc_health_v1.HealthCheckResponse]) error {
+	dbName, err := getCurrentDBName(stream.Context(), s.ngy)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	s.dbNames <- dbName
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+func TestServerInterceptors(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newMySQLNagayaForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := &tenantHealthServer{ngy: ngy, dbNames: make(chan string, 1)}
+	client := newHealthClientForTesting(t, hs,
+		grpc.UnaryInterceptor(nagaya.UnaryServerInterceptor(ngy, nagaya.DecideTenantFromMetadata("tenant-id"))),
+		grpc.StreamInterceptor(nagaya.StreamServerInterceptor(ngy, nagaya.DecideTenantFromMetadata("tenant-id"))))
+
+	testCases := []struct {
+		name       string
+		tenant     string
+		wantCode   codes.Code
+		wantDBName string
+	}{
+		{name: "ok", tenant: "tenant_1", wantCode: codes.OK, wantDBName: "tenant_1"},
+		{name: "ng/no tenant", wantCode: codes.InvalidArgument},
+		{name: "ng/invalid tenant", tenant: "x; drop database y", wantCode: codes.InvalidArgument},
+		{name: "ng/unknown tenant", tenant: "tenant_non_existent", wantCode: codes.NotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := t.Context()
+			if tc.tenant != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "tenant-id", tc.tenant)
+			}
+
+			_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if code := status.Code(err); code != tc.wantCode {
+				t.Errorf("Check: unexpected code: want=%s got=%s (%v)", tc.wantCode, code, err)
+			}
+			if tc.wantCode == codes.OK {
+				if got := <-hs.dbNames; got != tc.wantDBName {
+					t.Errorf("Check: unexpected DB: want=%s got=%s", tc.wantDBName, got)
+				}
+			}
+
+			stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = stream.Recv()
+			if code := status.Code(err); code != tc.wantCode {
+				t.Errorf("Watch: unexpected code: want=%s got=%s (%v)", tc.wantCode, code, err)
+			}
+			if tc.wantCode == codes.OK {
+				if got := <-hs.dbNames; got != tc.wantDBName {
+					t.Errorf("Watch: unexpected DB: want=%s got=%s", tc.wantDBName, got)
+				}
+			}
+		})
+	}
+}
+
+func TestServerInterceptors_withTenantRegistry(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newMySQLNagayaForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := nagaya.NewInMemoryTenantRegistry(nagaya.TenantInfo{Tenant: "tenant_1"})
+	hs := &tenantHealthServer{ngy: ngy, dbNames: make(chan string, 1)}
+	client := newHealthClientForTesting(t, hs,
+		grpc.UnaryInterceptor(nagaya.UnaryServerInterceptor(ngy, nagaya.DecideTenantFromMetadata("tenant-id"), nagaya.WithTenantRegistry(registry))))
+
+	ctx := metadata.AppendToOutgoingContext(t.Context(), "tenant-id", "tenant_2")
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound but got: %v", err)
+	}
+}
+
+func newHealthClientForTesting(t *testing.T, hs grpc_health_v1.HealthServer, opts ...grpc.ServerOption) grpc_health_v1.HealthClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(opts...)
+	grpc_health_v1.RegisterHealthServer(srv, hs)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }
+	cc, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cc.Close() })
+	return grpc_health_v1.NewHealthClient(cc)
+}
@@ -0,0 +1,104 @@
+package nagaya
+
+import (
+	"context"
+	"fmt"
+)
+
+// HookPoint is a point of the lifecycle of the connection that the hooks run at.
+type HookPoint string
+
+const (
+	// HookBeforeBind runs after the connection is obtained from the DB and before the tenant is switched.
+	HookBeforeBind HookPoint = "BeforeBind"
+	// HookAfterBind runs after the tenant is switched and before the connection is passed to the handler.
+	HookAfterBind HookPoint = "AfterBind"
+	// HookBeforeRelease runs in [Nagaya.RestoreConnection] before the tenant is reset.
+	HookBeforeRelease HookPoint = "BeforeRelease"
+)
+
+// HookEvent conveys the connection and what it is bound for to the hooks.
+type HookEvent[Conn Connish] struct {
+	Tenant    Tenant
+	RequestID string
+	// Conn is the zero value for [OnBindError] hooks if the bind fails before the connection is obtained.
+	Conn Conn
+}
+
+// HookFunc is a function that runs at the [HookPoint].
+//
+// Returning an error aborts the bind or the release with [HookError].
+type HookFunc[Conn Connish] func(ctx context.Context, event *HookEvent[Conn]) error
+
+// BindErrorHookFunc is a function that runs when [Nagaya.BindConnection] fails.
+type BindErrorHookFunc[Conn Connish] func(ctx context.Context, event *HookEvent[Conn], err error)
+
+// BeforeBind registers the hook that runs at [HookBeforeBind].
+//
+// The type parameter Conn must be the same as the Nagaya's one, otherwise [New] panics.
+func BeforeBind[Conn Connish](fn HookFunc[Conn]) NewOption {
+	return &optHook{register: func(h *hooks[Conn]) { h.beforeBind = append(h.beforeBind, fn) }}
+}
+
+// AfterBind registers the hook that runs at [HookAfterBind].
+//
+// It is suitable to set up the session such as the time zone and sql_mode for the tenant.
+// The type parameter Conn must be the same as the Nagaya's one, otherwise [New] panics.
+func AfterBind[Conn Connish](fn HookFunc[Conn]) NewOption {
+	return &optHook{register: func(h *hooks[Conn]) { h.afterBind = append(h.afterBind, fn) }}
+}
+
+// BeforeRelease registers the hook that runs at [HookBeforeRelease].
+//
+// It is suitable to clean up the session that set up by [AfterBind] hooks.
+// If the hook fails, the connection is discarded instead of being returned to the pool.
+// The type parameter Conn must be the same as the Nagaya's one, otherwise [New] panics.
+func BeforeRelease[Conn Connish](fn HookFunc[Conn]) NewOption {
+	return &optHook{register: func(h *hooks[Conn]) { h.beforeRelease = append(h.beforeRelease, fn) }}
+}
+
+// OnBindError registers the hook that runs when [Nagaya.BindConnection] fails including the failures of other hooks.
+//
+// The connection in the event has already been discarded and closed, so it is only for identifying the connection.
+// The type parameter Conn must be the same as the Nagaya's one, otherwise [New] panics.
+func OnBindError[Conn Connish](fn BindErrorHookFunc[Conn]) NewOption {
+	return &optHook{register: func(h *hooks[Conn]) { h.onBindError = append(h.onBindError, fn) }}
+}
+
+type hooks[Conn Connish] struct {
+	beforeBind    []HookFunc[Conn]
+	afterBind     []HookFunc[Conn]
+	beforeRelease []HookFunc[Conn]
+	onBindError   []BindErrorHookFunc[Conn]
+}
+
+// newHooks builds the hooks from the registrations given by the options.
+//
+// The registrations are typed as any because [NewOption] cannot know the Nagaya's type parameters.
+func newHooks[Conn Connish](registrations []any) *hooks[Conn] {
+	h := new(hooks[Conn])
+	for _, r := range registrations {
+		register, ok := r.(func(*hooks[Conn]))
+		if !ok {
+			var conn Conn
+			panic(fmt.Sprintf("nagaya: the hook cannot be registered for the Nagaya of %T", conn))
+		}
+		register(h)
+	}
+	return h
+}
+
+func (h *hooks[Conn]) run(ctx context.Context, point HookPoint, fns []HookFunc[Conn], event *HookEvent[Conn]) error {
+	for _, fn := range fns {
+		if err := fn(ctx, event); err != nil {
+			return &HookError{err: err, point: point, tenant: event.Tenant}
+		}
+	}
+	return nil
+}
+
+func (h *hooks[Conn]) bindFailed(ctx context.Context, event *HookEvent[Conn], err error) {
+	for _, fn := range h.onBindError {
+		fn(ctx, event, err)
+	}
+}
@@ -0,0 +1,173 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/aereal/nagaya"
+)
+
+type hookRecorder struct {
+	mux    sync.Mutex
+	events []string
+}
+
+func (r *hookRecorder) record(name string, event *nagaya.HookEvent[*sql.Conn]) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.events = append(r.events, fmt.Sprintf("%s:%s:%s:%t", name, event.Tenant, event.RequestID, event.Conn != nil))
+}
+
+func (r *hookRecorder) hook(name string, err error) nagaya.HookFunc[*sql.Conn] {
+	return func(_ context.Context, event *nagaya.HookEvent[*sql.Conn]) error {
+		r.record(name, event)
+		return err
+	}
+}
+
+func (r *hookRecorder) options(errs map[nagaya.HookPoint]error) []nagaya.NewOption {
+	return []nagaya.NewOption{
+		nagaya.BeforeBind(r.hook("BeforeBind", errs[nagaya.HookBeforeBind])),
+		nagaya.AfterBind(r.hook("AfterBind", errs[nagaya.HookAfterBind])),
+		nagaya.BeforeRelease(r.hook("BeforeRelease", errs[nagaya.HookBeforeRelease])),
+		nagaya.OnBindError(func(_ context.Context, event *nagaya.HookEvent[*sql.Conn], _ error) {
+			r.record("OnBindError", event)
+		}),
+	}
+}
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+
+	errHook := errors.New("hook failed")
+	testCases := []struct {
+		name       string
+		tenant     nagaya.Tenant
+		switchErr  error
+		hookErrs   map[nagaya.HookPoint]error
+		wantErr    error
+		wantPoint  nagaya.HookPoint
+		wantEvents []string
+	}{
+		{
+			name:   "ok",
+			tenant: "tenant_1",
+			wantEvents: []string{
+				"BeforeBind:tenant_1:req_1:true",
+				"AfterBind:tenant_1:req_1:true",
+				"BeforeRelease:tenant_1:req_1:true",
+			},
+		},
+		{
+			name:      "before bind fails",
+			tenant:    "tenant_1",
+			hookErrs:  map[nagaya.HookPoint]error{nagaya.HookBeforeBind: errHook},
+			wantErr:   errHook,
+			wantPoint: nagaya.HookBeforeBind,
+			wantEvents: []string{
+				"BeforeBind:tenant_1:req_1:true",
+				"OnBindError:tenant_1:req_1:true",
+			},
+		},
+		{
+			name:      "after bind fails",
+			tenant:    "tenant_1",
+			hookErrs:  map[nagaya.HookPoint]error{nagaya.HookAfterBind: errHook},
+			wantErr:   errHook,
+			wantPoint: nagaya.HookAfterBind,
+			wantEvents: []string{
+				"BeforeBind:tenant_1:req_1:true",
+				"AfterBind:tenant_1:req_1:true",
+				"OnBindError:tenant_1:req_1:true",
+			},
+		},
+		{
+			name:      "switch fails",
+			tenant:    "tenant_1",
+			switchErr: errUnknownDatabase,
+			wantErr:   errUnknownDatabase,
+			wantEvents: []string{
+				"BeforeBind:tenant_1:req_1:true",
+				"OnBindError:tenant_1:req_1:true",
+			},
+		},
+		{
+			name:    "invalid tenant",
+			tenant:  "x;y",
+			wantErr: nagaya.ErrInvalidTenant,
+			wantEvents: []string{
+				"OnBindError:x;y:req_1:false",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db := sql.OpenDB(stubConnector{})
+			t.Cleanup(func() { _ = db.Close() })
+			recorder := new(hookRecorder)
+			opts := append(recorder.options(tc.hookErrs), nagaya.WithTenantSwitcher(&failingSwitcher{err: tc.switchErr}))
+			ngy := nagaya.NewStd(db, opts...)
+			err := nagaya.Do(t.Context(), ngy, func(context.Context) error { return nil },
+				nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: tc.tenant}),
+				nagaya.WithRequestIDGenerator(nagaya.RequestIDGeneratorFunc(func() (string, error) { return "req_1", nil })))
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("error:\n\twant: %v\n\t got: %v", tc.wantErr, err)
+			}
+			if tc.wantPoint != "" {
+				var hookErr *nagaya.HookError
+				if !errors.As(err, &hookErr) {
+					t.Fatalf("expected HookError but got %T", err)
+				}
+				if hookErr.Point() != tc.wantPoint {
+					t.Errorf("hook point:\n\twant: %s\n\t got: %s", tc.wantPoint, hookErr.Point())
+				}
+				if hookErr.Tenant() != tc.tenant {
+					t.Errorf("tenant:\n\twant: %s\n\t got: %s", tc.tenant, hookErr.Tenant())
+				}
+			}
+			if !slices.Equal(recorder.events, tc.wantEvents) {
+				t.Errorf("events:\n\twant: %q\n\t got: %q", tc.wantEvents, recorder.events)
+			}
+		})
+	}
+}
+
+func TestHooks_beforeReleaseFails(t *testing.T) {
+	t.Parallel()
+
+	db := sql.OpenDB(stubConnector{})
+	t.Cleanup(func() { _ = db.Close() })
+	errHook := errors.New("hook failed")
+	ngy := nagaya.NewStd(db, nagaya.WithTenantSwitcher(&failingSwitcher{}), nagaya.BeforeRelease(func(context.Context, *nagaya.HookEvent[*sql.Conn]) error { return errHook }))
+	ctx := nagaya.ContextWithRequestID(nagaya.WithTenant(t.Context(), "tenant_1"), "req_1")
+	conn, err := ngy.BindConnection(ctx, "tenant_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ngy.ReleaseConnection("req_1")
+	err = ngy.RestoreConnection(ctx, conn)
+	var hookErr *nagaya.HookError
+	if !errors.As(err, &hookErr) || hookErr.Point() != nagaya.HookBeforeRelease || !errors.Is(err, errHook) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHooks_mismatchedConn(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected New panics")
+		}
+	}()
+	db := sql.OpenDB(stubConnector{})
+	t.Cleanup(func() { _ = db.Close() })
+	_ = nagaya.NewStd(db, nagaya.AfterBind(func(context.Context, *nagaya.HookEvent[*sql.DB]) error { return nil }))
+}
@@ -0,0 +1,179 @@
+package nagaya
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostTenantLookup looks up the tenant that owns the host.
+type HostTenantLookup interface {
+	// LookupHostTenant returns the tenant that owns the host.
+	//
+	// It returns [ErrUnknownHost] if no tenant owns the host.
+	LookupHostTenant(ctx context.Context, host string) (Tenant, error)
+}
+
+// HostTenantLookupFunc is a function that implements [HostTenantLookup].
+type HostTenantLookupFunc func(ctx context.Context, host string) (Tenant, error)
+
+var _ HostTenantLookup = (HostTenantLookupFunc)(nil)
+
+func (f HostTenantLookupFunc) LookupHostTenant(ctx context.Context, host string) (Tenant, error) {
+	return f(ctx, host)
+}
+
+// HostTenantMap is a [HostTenantLookup] that maps the lower-cased hosts to the tenants.
+type HostTenantMap map[string]Tenant
+
+var _ HostTenantLookup = (HostTenantMap)(nil)
+
+func (m HostTenantMap) LookupHostTenant(_ context.Context, host string) (Tenant, error) {
+	tenant, ok := m[host]
+	if !ok {
+		return "", ErrUnknownHost
+	}
+	return tenant, nil
+}
+
+const defaultHostCacheSize = 10000
+
+// CachedHostTenantLookup is a [HostTenantLookup] that caches the results of another one.
+//
+// Both the found tenants and [ErrUnknownHost] are cached, while the other errors are not.
+// The number of the cached hosts is bounded by [WithHostCacheSize] because the hosts are given by the clients;
+// when the cache is full, the expired entries are swept, and then the misses are no longer cached
+// and the found tenants evict an arbitrary entry.
+// It is safe for concurrent use.
+type CachedHostTenantLookup struct {
+	lookup     HostTenantLookup
+	entries    map[string]hostTenantCacheEntry
+	ttl        time.Duration
+	maxEntries int
+	mux        sync.RWMutex
+}
+
+type hostTenantCacheEntry struct {
+	expiresAt time.Time
+	err       error
+	tenant    Tenant
+}
+
+var _ HostTenantLookup = (*CachedHostTenantLookup)(nil)
+
+// NewCachedHostTenantLookup returns a new [CachedHostTenantLookup] that keeps the results for the ttl.
+func NewCachedHostTenantLookup(lookup HostTenantLookup, ttl time.Duration, opts ...HostCacheOption) *CachedHostTenantLookup {
+	cfg := &hostCacheConfig{maxEntries: defaultHostCacheSize}
+	for _, o := range opts {
+		o.applyHostCacheOption(cfg)
+	}
+	return &CachedHostTenantLookup{lookup: lookup, ttl: ttl, maxEntries: cfg.maxEntries, entries: make(map[string]hostTenantCacheEntry)}
+}
+
+func (c *CachedHostTenantLookup) LookupHostTenant(ctx context.Context, host string) (Tenant, error) {
+	now := time.Now()
+	c.mux.RLock()
+	entry, ok := c.entries[host]
+	c.mux.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.tenant, entry.err
+	}
+
+	tenant, err := c.lookup.LookupHostTenant(ctx, host)
+	if err != nil && !errors.Is(err, ErrUnknownHost) {
+		return "", err
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.makeRoom(host, now, err == nil) {
+		c.entries[host] = hostTenantCacheEntry{tenant: tenant, err: err, expiresAt: now.Add(c.ttl)}
+	}
+	return tenant, err
+}
+
+// makeRoom tells whether the host can be cached, sweeping the expired entries or evicting one if the cache is full.
+//
+// It must be called with the lock held.
+func (c *CachedHostTenantLookup) makeRoom(host string, now time.Time, found bool) bool {
+	if _, ok := c.entries[host]; ok || len(c.entries) < c.maxEntries {
+		return true
+	}
+	for h, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, h)
+		}
+	}
+	if len(c.entries) < c.maxEntries {
+		return true
+	}
+	if !found || c.maxEntries <= 0 {
+		return false
+	}
+	for h := range c.entries {
+		delete(c.entries, h)
+		break
+	}
+	return true
+}
+
+// Purge removes the cached result of the host.
+func (c *CachedHostTenantLookup) Purge(host string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.entries, host)
+}
+
+// DecideTenantFromHost returns a [DecideRequestTenantFunc] that looks up the tenant by the full hostname of the request.
+//
+// It is useful for the tenants that bring their own domains.
+// The host is lower-cased and its port is stripped before the lookup.
+// If the lookup misses, it decides [TenantDecisionResultError] with [ErrUnknownHost].
+//
+// The X-Forwarded-Host header is respected only if the request comes from the proxies given by [WithTrustedProxies].
+// Its rightmost value is taken because it is the one added by the trusted proxy, while the others may be forged by the client.
+func DecideTenantFromHost(lookup HostTenantLookup, opts ...HostOption) DecideRequestTenantFunc {
+	cfg := new(hostConfig)
+	for _, o := range opts {
+		o.applyHostOption(cfg)
+	}
+	return func(r *http.Request) TenantDecisionResult {
+		host := cfg.requestHost(r)
+		if host == "" {
+			return &TenantDecisionResultError{Err: ErrUnknownHost}
+		}
+		tenant, err := lookup.LookupHostTenant(r.Context(), host)
+		if err != nil {
+			return &TenantDecisionResultError{Err: err}
+		}
+		return &TenantDecisionResultChangeTenant{Tenant: tenant}
+	}
+}
+
+func (cfg *hostConfig) requestHost(r *http.Request) string {
+	host := r.Host
+	if forwarded := r.Header.Get("x-forwarded-host"); forwarded != "" && cfg.trusts(r.RemoteAddr) {
+		host = forwarded[strings.LastIndex(forwarded, ",")+1:]
+	}
+	return strings.TrimSuffix(strings.ToLower(stripPort(strings.TrimSpace(host))), ".")
+}
+
+func (cfg *hostConfig) trusts(remoteAddr string) bool {
+	if len(cfg.trustedProxies) == 0 {
+		return false
+	}
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, prefix := range cfg.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package nagaya_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+func TestDecideTenantFromHost(t *testing.T) {
+	t.Parallel()
+
+	lookup := nagaya.HostTenantMap{
+		"crm.customer.co.jp": "tenant_1",
+		"app.example.com":    "tenant_2",
+	}
+	trusted := nagaya.WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8"))
+	testCases := []struct {
+		name          string
+		host          string
+		forwardedHost string
+		remoteAddr    string
+		opts          []nagaya.HostOption
+		wantDecision  nagaya.TenantDecision
+		wantTenant    nagaya.Tenant
+		wantErr       error
+	}{
+		{name: "found", host: "crm.customer.co.jp", wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "tenant_1"},
+		{name: "uppercase with port", host: "CRM.customer.co.jp:443", wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "tenant_1"},
+		{name: "trailing dot", host: "crm.customer.co.jp.", wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "tenant_1"},
+		{name: "unknown host", host: "unknown.example.com", wantDecision: nagaya.TenantDecisionError, wantErr: nagaya.ErrUnknownHost},
+		{
+			name:          "forwarded host from trusted proxy",
+			host:          "internal.lb",
+			forwardedHost: "app.example.com",
+			remoteAddr:    "10.1.2.3:50000",
+			opts:          []nagaya.HostOption{trusted},
+			wantDecision:  nagaya.TenantDecisionChangeTenant,
+			wantTenant:    "tenant_2",
+		},
+		{
+			name:          "forwarded host forged before trusted proxy",
+			host:          "internal.lb",
+			forwardedHost: "crm.customer.co.jp, app.example.com",
+			remoteAddr:    "10.1.2.3:50000",
+			opts:          []nagaya.HostOption{trusted},
+			wantDecision:  nagaya.TenantDecisionChangeTenant,
+			wantTenant:    "tenant_2",
+		},
+		{
+			name:          "forwarded host from untrusted client",
+			host:          "crm.customer.co.jp",
+			forwardedHost: "app.example.com",
+			remoteAddr:    "192.0.2.1:50000",
+			opts:          []nagaya.HostOption{trusted},
+			wantDecision:  nagaya.TenantDecisionChangeTenant,
+			wantTenant:    "tenant_1",
+		},
+		{
+			name:          "forwarded host without trusted proxies",
+			host:          "crm.customer.co.jp",
+			forwardedHost: "app.example.com",
+			remoteAddr:    "10.1.2.3:50000",
+			wantDecision:  nagaya.TenantDecisionChangeTenant,
+			wantTenant:    "tenant_1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tc.host
+			if tc.remoteAddr != "" {
+				r.RemoteAddr = tc.remoteAddr
+			}
+			if tc.forwardedHost != "" {
+				r.Header.Set("x-forwarded-host", tc.forwardedHost)
+			}
+			assertTenantDecisionResult(t, nagaya.DecideTenantFromHost(lookup, tc.opts...)(r), tc.wantDecision, tc.wantTenant, tc.wantErr)
+		})
+	}
+}
+
+var errLookupUnavailable = errors.New("lookup unavailable")
+
+func TestCachedHostTenantLookup(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	base := nagaya.HostTenantLookupFunc(func(_ context.Context, host string) (nagaya.Tenant, error) {
+		calls.Add(1)
+		switch host {
+		case "app.example.com":
+			return "tenant_1", nil
+		case "flaky.example.com":
+			return "", errLookupUnavailable
+		default:
+			return "", nagaya.ErrUnknownHost
+		}
+	})
+	lookup := nagaya.NewCachedHostTenantLookup(base, time.Minute)
+	ctx := t.Context()
+
+	for range 2 {
+		if tenant, err := lookup.LookupHostTenant(ctx, "app.example.com"); err != nil || tenant != "tenant_1" {
+			t.Errorf("unexpected result: tenant=%s err=%v", tenant, err)
+		}
+		if _, err := lookup.LookupHostTenant(ctx, "unknown.example.com"); !errors.Is(err, nagaya.ErrUnknownHost) {
+			t.Errorf("expected ErrUnknownHost but got: %v", err)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("the hits and misses must be cached: calls=%d", got)
+	}
+
+	for range 2 {
+		if _, err := lookup.LookupHostTenant(ctx, "flaky.example.com"); !errors.Is(err, errLookupUnavailable) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("the errors must not be cached: calls=%d", got)
+	}
+
+	lookup.Purge("app.example.com")
+	if _, err := lookup.LookupHostTenant(ctx, "app.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 5 {
+		t.Errorf("the purged host must be looked up again: calls=%d", got)
+	}
+}
+
+func TestCachedHostTenantLookup_size(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	base := nagaya.HostTenantLookupFunc(func(_ context.Context, host string) (nagaya.Tenant, error) {
+		calls.Add(1)
+		if host == "app.example.com" {
+			return "tenant_1", nil
+		}
+		return "", nagaya.ErrUnknownHost
+	})
+	lookup := nagaya.NewCachedHostTenantLookup(base, time.Minute, nagaya.WithHostCacheSize(2))
+	ctx := t.Context()
+
+	for _, host := range []string{"app.example.com", "unknown-1.example.com", "unknown-2.example.com", "unknown-2.example.com", "app.example.com"} {
+		_, _ = lookup.LookupHostTenant(ctx, host)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("the misses over the size must not be cached: calls=%d", got)
+	}
+}
@@ -0,0 +1,209 @@
+package nagaya
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const defaultTenantClaim = "tenant"
+
+// DecideTenantFromJWT returns a [DecideRequestTenantFunc] that uses the claim of the verified bearer token as the tenant.
+//
+// The token is taken from the Authorization header and verified by the keys given by [WithHMACSecret], [WithVerificationKey] or [WithJWKS].
+// The tenant is read from the "tenant" claim unless [WithTenantClaim] is given.
+// If the token is missing or cannot be verified, it decides [TenantDecisionResultError] with a [TokenVerificationError].
+func DecideTenantFromJWT(opts ...JWTOption) DecideRequestTenantFunc {
+	cfg := &jwtConfig{claim: defaultTenantClaim}
+	for _, o := range opts {
+		o.applyJWTOption(cfg)
+	}
+	parserOpts := append([]jwt.ParserOption{jwt.WithValidMethods(cfg.validMethods())}, cfg.parserOpts...)
+	parser := jwt.NewParser(parserOpts...)
+	return func(r *http.Request) TenantDecisionResult {
+		raw, ok := bearerToken(r)
+		if !ok {
+			return &TenantDecisionResultError{Err: &TokenVerificationError{err: ErrNoBearerToken}}
+		}
+		claims := jwt.MapClaims{}
+		if _, err := parser.ParseWithClaims(raw, claims, cfg.keyFunc); err != nil {
+			return &TenantDecisionResultError{Err: &TokenVerificationError{err: err}}
+		}
+		tenant, ok := claims[cfg.claim].(string)
+		if !ok || tenant == "" {
+			return &TenantDecisionResultError{Err: &TokenVerificationError{err: ErrNoTenantClaim}}
+		}
+		return &TenantDecisionResultChangeTenant{Tenant: Tenant(tenant)}
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+type jwtKey struct {
+	key any
+	id  string
+}
+
+func (cfg *jwtConfig) validMethods() []string {
+	var methods []string
+	var hasHMAC, hasRSA, hasECDSA bool
+	for _, k := range cfg.keys {
+		switch k.key.(type) {
+		case []byte:
+			hasHMAC = true
+		case *rsa.PublicKey:
+			hasRSA = true
+		case *ecdsa.PublicKey:
+			hasECDSA = true
+		}
+	}
+	if hasHMAC {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if hasRSA {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512")
+	}
+	if hasECDSA {
+		methods = append(methods, "ES256", "ES384", "ES512")
+	}
+	return methods
+}
+
+// keyFunc returns the keys that match the signing method and the key ID of the token.
+func (cfg *jwtConfig) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	var set jwt.VerificationKeySet
+	for _, k := range cfg.keys {
+		if kid != "" && k.id != "" && kid != k.id {
+			continue
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if secret, ok := k.key.([]byte); ok {
+				set.Keys = append(set.Keys, secret)
+			}
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+			if key, ok := k.key.(*rsa.PublicKey); ok {
+				set.Keys = append(set.Keys, key)
+			}
+		case *jwt.SigningMethodECDSA:
+			if key, ok := k.key.(*ecdsa.PublicKey); ok {
+				set.Keys = append(set.Keys, key)
+			}
+		}
+	}
+	if len(set.Keys) == 0 {
+		return nil, ErrNoVerificationKey
+	}
+	return set, nil
+}
+
+// JWKS is a JSON Web Key Set that holds the keys to verify the tokens.
+type JWKS struct {
+	keys []jwtKey
+}
+
+// ParseJWKS parses the JSON Web Key Set defined by RFC 7517.
+//
+// It supports RSA, EC (P-256, P-384 and P-521) and oct keys. The keys for other uses than signature are ignored.
+func ParseJWKS(data []byte) (*JWKS, error) {
+	var doc struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	set := &JWKS{keys: make([]jwtKey, 0, len(doc.Keys))}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var (
+			key any
+			err error
+		)
+		switch k.Kty {
+		case "RSA":
+			key, err = parseRSAJWK(k.N, k.E)
+		case "EC":
+			key, err = parseECJWK(k.Crv, k.X, k.Y)
+		case "oct":
+			key, err = base64.RawURLEncoding.DecodeString(k.K)
+		default:
+			err = &InvalidJWKError{kid: k.Kid, reason: fmt.Sprintf("unsupported key type %q", k.Kty)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		set.keys = append(set.keys, jwtKey{id: k.Kid, key: key})
+	}
+	return set, nil
+}
+
+func parseRSAJWK(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+		return nil, &InvalidJWKError{reason: "too large RSA exponent"}
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+func parseECJWK(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, &InvalidJWKError{reason: fmt.Sprintf("unsupported curve %q", crv)}
+	}
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}
+	if !curve.IsOnCurve(key.X, key.Y) { //nolint:staticcheck // no alternative to validate the raw coordinates
+		return nil, &InvalidJWKError{reason: "the point is not on the curve"}
+	}
+	return key, nil
+}
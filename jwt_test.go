@@ -0,0 +1,217 @@
+package nagaya_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestDecideTenantFromJWT(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("s3cr3t")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := nagaya.ParseJWKS(mustMarshalJSON(t, map[string]any{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+			{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "", "e": ""},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := jwt.MapClaims{"tenant": "tenant_1", "exp": time.Now().Add(time.Hour).Unix()}
+	testCases := []struct {
+		name         string
+		opts         []nagaya.JWTOption
+		token        string
+		wantDecision nagaya.TenantDecision
+		wantTenant   nagaya.Tenant
+		wantErr      error
+	}{
+		{
+			name:         "HMAC",
+			opts:         []nagaya.JWTOption{nagaya.WithHMACSecret(secret)},
+			token:        signToken(t, jwt.SigningMethodHS256, secret, "", valid),
+			wantDecision: nagaya.TenantDecisionChangeTenant,
+			wantTenant:   "tenant_1",
+		},
+		{
+			name:         "RSA",
+			opts:         []nagaya.JWTOption{nagaya.WithVerificationKey("", &rsaKey.PublicKey)},
+			token:        signToken(t, jwt.SigningMethodRS256, rsaKey, "", valid),
+			wantDecision: nagaya.TenantDecisionChangeTenant,
+			wantTenant:   "tenant_1",
+		},
+		{
+			name:         "JWKS/RSA",
+			opts:         []nagaya.JWTOption{nagaya.WithJWKS(jwks)},
+			token:        signToken(t, jwt.SigningMethodPS256, rsaKey, "rsa-1", valid),
+			wantDecision: nagaya.TenantDecisionChangeTenant,
+			wantTenant:   "tenant_1",
+		},
+		{
+			name:         "JWKS/ECDSA",
+			opts:         []nagaya.JWTOption{nagaya.WithJWKS(jwks)},
+			token:        signToken(t, jwt.SigningMethodES256, ecKey, "ec-1", valid),
+			wantDecision: nagaya.TenantDecisionChangeTenant,
+			wantTenant:   "tenant_1",
+		},
+		{
+			name:         "custom claim",
+			opts:         []nagaya.JWTOption{nagaya.WithHMACSecret(secret), nagaya.WithTenantClaim("org")},
+			token:        signToken(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"org": "tenant_2"}),
+			wantDecision: nagaya.TenantDecisionChangeTenant,
+			wantTenant:   "tenant_2",
+		},
+		{
+			name:         "no token",
+			opts:         []nagaya.JWTOption{nagaya.WithHMACSecret(secret)},
+			wantDecision: nagaya.TenantDecisionError,
+			wantErr:      nagaya.ErrNoBearerToken,
+		},
+		{
+			name:         "wrong secret",
+			opts:         []nagaya.JWTOption{nagaya.WithHMACSecret(secret)},
+			token:        signToken(t, jwt.SigningMethodHS256, []byte("other"), "", valid),
+			wantDecision: nagaya.TenantDecisionError,
+			wantErr:      jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:         "unknown kid",
+			opts:         []nagaya.JWTOption{nagaya.WithJWKS(jwks)},
+			token:        signToken(t, jwt.SigningMethodES256, ecKey, "ec-2", valid),
+			wantDecision: nagaya.TenantDecisionError,
+			wantErr:      nagaya.ErrNoVerificationKey,
+		},
+		{
+			name:         "algorithm confusion",
+			opts:         []nagaya.JWTOption{nagaya.WithVerificationKey("", &rsaKey.PublicKey)},
+			token:        signToken(t, jwt.SigningMethodHS256, []byte("whatever"), "", valid),
+			wantDecision: nagaya.TenantDecisionError,
+			wantErr:      jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name:         "expired",
+			opts:         []nagaya.JWTOption{nagaya.WithHMACSecret(secret)},
+			token:        signToken(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"tenant": "tenant_1", "exp": time.Now().Add(-time.Hour).Unix()}),
+			wantDecision: nagaya.TenantDecisionError,
+			wantErr:      jwt.ErrTokenExpired,
+		},
+		{
+			name:         "no tenant claim",
+			opts:         []nagaya.JWTOption{nagaya.WithHMACSecret(secret)},
+			token:        signToken(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "user"}),
+			wantDecision: nagaya.TenantDecisionError,
+			wantErr:      nagaya.ErrNoTenantClaim,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.token != "" {
+				r.Header.Set("authorization", "Bearer "+tc.token)
+			}
+			ret := nagaya.DecideTenantFromJWT(tc.opts...)(r)
+			assertTenantDecisionResult(t, ret, tc.wantDecision, tc.wantTenant, tc.wantErr)
+			if _, err := ret.DecideTenant(); err != nil && !errors.As(err, new(*nagaya.TokenVerificationError)) {
+				t.Errorf("expected TokenVerificationError but got: %T", err)
+			}
+		})
+	}
+}
+
+func TestDecideTenantFromJWT_firstOf(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("s3cr3t")
+	decide := nagaya.FirstOf(nagaya.DecideTenantFromJWT(nagaya.WithHMACSecret(secret)), nagaya.HeaderTenant("tenant-id"))
+	valid := jwt.MapClaims{"tenant": "tenant_1", "exp": time.Now().Add(time.Hour).Unix()}
+	testCases := []struct {
+		name         string
+		token        string
+		header       string
+		wantDecision nagaya.TenantDecision
+		wantTenant   nagaya.Tenant
+		wantErr      error
+	}{
+		{name: "valid JWT", token: signToken(t, jwt.SigningMethodHS256, secret, "", valid), header: "tenant_2", wantDecision: nagaya.TenantDecisionChangeTenant, wantTenant: "tenant_1"},
+		{name: "invalid JWT + header", token: signToken(t, jwt.SigningMethodHS256, []byte("other"), "", valid), header: "tenant_2", wantDecision: nagaya.TenantDecisionError, wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "no token + header", header: "tenant_2", wantDecision: nagaya.TenantDecisionError, wantErr: nagaya.ErrNoBearerToken},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.token != "" {
+				r.Header.Set("authorization", "Bearer "+tc.token)
+			}
+			r.Header.Set("tenant-id", tc.header)
+			assertTenantDecisionResult(t, decide(r), tc.wantDecision, tc.wantTenant, tc.wantErr)
+		})
+	}
+}
+
+func TestParseJWKS_invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []string{
+		`{`,
+		`{"keys":[{"kty":"OKP","kid":"ed-1"}]}`,
+		`{"keys":[{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}]}`,
+	} {
+		if _, err := nagaya.ParseJWKS([]byte(data)); err == nil {
+			t.Errorf("expected an error for %s", data)
+		}
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func mustMarshalJSON(t *testing.T, v any) []byte {
+	t.Helper()
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,71 @@
+package nagaya
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// LogHandler is a [slog.Handler] that adds the tenant and the request ID in the context to each record.
+//
+// The attributes are added by the keys [KeyTenant] and [KeyRequestID] unless the record already has them.
+type LogHandler struct {
+	base slog.Handler
+}
+
+var _ slog.Handler = (*LogHandler)(nil)
+
+// NewLogHandler returns a new [LogHandler] that wraps the base handler.
+func NewLogHandler(base slog.Handler) *LogHandler {
+	return &LogHandler{base: base}
+}
+
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.base.Enabled(ctx, level)
+}
+
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	var hasTenant, hasReqID bool
+	record.Attrs(func(attr slog.Attr) bool {
+		switch attr.Key {
+		case string(KeyTenant):
+			hasTenant = true
+		case string(KeyRequestID):
+			hasReqID = true
+		}
+		return !hasTenant || !hasReqID
+	})
+	if tenant, ok := TenantFromContext(ctx); ok && !hasTenant {
+		record.AddAttrs(logAttrTenant(tenant))
+	}
+	if reqID, ok := RequestIDFromContext(ctx); ok && !hasReqID {
+		record.AddAttrs(logAttrRequestID(reqID))
+	}
+	return h.base.Handle(ctx, record)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{base: h.base.WithAttrs(attrs)}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{base: h.base.WithGroup(name)}
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+func logAttrTenant(tenant Tenant) slog.Attr { return slog.String(string(KeyTenant), string(tenant)) }
+
+func logAttrRequestID(reqID string) slog.Attr { return slog.String(string(KeyRequestID), reqID) }
+
+// errorLogLevel returns the level to log the error.
+//
+// The errors caused by the clients such as invalid tenants are logged as warnings and the others are logged as errors.
+func errorLogLevel(err error) slog.Level {
+	for _, fn := range defaultErrorStatusFuncs {
+		if status, ok := fn(err); ok && status < http.StatusInternalServerError {
+			return slog.LevelWarn
+		}
+	}
+	return slog.LevelError
+}
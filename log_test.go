@@ -0,0 +1,169 @@
+package nagaya_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/aereal/nagaya"
+)
+
+func TestLogHandler(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		ctx    func(ctx context.Context) context.Context
+		logger func(logger *slog.Logger) *slog.Logger
+		attrs  []any
+		want   map[string]any
+	}{
+		{
+			name: "no tenant",
+			ctx:  func(ctx context.Context) context.Context { return ctx },
+			want: map[string]any{"msg": "hello"},
+		},
+		{
+			name: "tenant and request ID",
+			ctx: func(ctx context.Context) context.Context {
+				return nagaya.ContextWithRequestID(nagaya.WithTenant(ctx, "tenant_1"), "req_1")
+			},
+			want: map[string]any{"msg": "hello", "nagaya.tenant": "tenant_1", "nagaya.request_id": "req_1"},
+		},
+		{
+			name:  "explicit attribute wins",
+			ctx:   func(ctx context.Context) context.Context { return nagaya.WithTenant(ctx, "tenant_1") },
+			attrs: []any{slog.String("nagaya.tenant", "tenant_2")},
+			want:  map[string]any{"msg": "hello", "nagaya.tenant": "tenant_2"},
+		},
+		{
+			name:   "with attrs",
+			ctx:    func(ctx context.Context) context.Context { return nagaya.WithTenant(ctx, "tenant_1") },
+			logger: func(logger *slog.Logger) *slog.Logger { return logger.With("app", "test") },
+			want:   map[string]any{"msg": "hello", "app": "test", "nagaya.tenant": "tenant_1"},
+		},
+		{
+			name:   "with group",
+			ctx:    func(ctx context.Context) context.Context { return nagaya.WithTenant(ctx, "tenant_1") },
+			logger: func(logger *slog.Logger) *slog.Logger { return logger.WithGroup("g") },
+			want:   map[string]any{"msg": "hello", "g": map[string]any{"nagaya.tenant": "tenant_1"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := new(bytes.Buffer)
+			logger := slog.New(nagaya.NewLogHandler(slog.NewJSONHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTimeAndLevel})))
+			if tc.logger != nil {
+				logger = tc.logger(logger)
+			}
+			logger.InfoContext(tc.ctx(t.Context()), "hello", tc.attrs...)
+			got := decodeLogRecords(t, buf)
+			if len(got) != 1 {
+				t.Fatalf("expected one record but got %d", len(got))
+			}
+			assertLogRecord(t, tc.want, got[0])
+		})
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	buf := new(bytes.Buffer)
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: dropTime}))
+	db := sql.OpenDB(stubConnector{})
+	t.Cleanup(func() { _ = db.Close() })
+	switcher := &failingSwitcher{}
+	ngy := nagaya.NewStd(db, nagaya.WithTenantSwitcher(switcher), nagaya.WithLogger(logger))
+	reqIDGen := nagaya.WithRequestIDGenerator(nagaya.RequestIDGeneratorFunc(func() (string, error) { return "req_1", nil }))
+
+	ctx := t.Context()
+	if err := nagaya.Do(ctx, ngy, func(context.Context) error { return nil }, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_1"}), reqIDGen); err != nil {
+		t.Fatal(err)
+	}
+	switcher.err = errUnknownDatabase
+	if err := nagaya.Do(ctx, ngy, func(context.Context) error { return nil }, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_1"}), reqIDGen); err == nil {
+		t.Fatal("expected an error")
+	}
+	doBuf := new(bytes.Buffer)
+	doLogger := slog.New(slog.NewJSONHandler(doBuf, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: dropTime}))
+	if err := nagaya.Do(ctx, ngy, func(context.Context) error { return nil }, nagaya.WithLogger(doLogger)); !errors.Is(err, nagaya.ErrNoTenantBound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reqIDGen = nagaya.WithRequestIDGenerator(nagaya.RequestIDGeneratorFunc(func() (string, error) { return "req_2", nil }))
+	if err := nagaya.Do(ctx, ngy, func(context.Context) error { return nil }, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_2"}), reqIDGen, nagaya.WithLogger(doLogger)); err == nil {
+		t.Fatal("expected an error")
+	}
+	switcher.err = nil
+	if err := nagaya.Do(ctx, ngy, func(context.Context) error { return nil }, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_2"}), reqIDGen, nagaya.WithLogger(doLogger)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertLogRecords(t, []map[string]any{
+		{"level": "DEBUG", "msg": "bound connection", "nagaya.tenant": "tenant_1", "nagaya.request_id": "req_1"},
+		{"level": "DEBUG", "msg": "released connection", "nagaya.tenant": "tenant_1", "nagaya.request_id": "req_1"},
+		{"level": "ERROR", "msg": "failed to bind connection", "nagaya.tenant": "tenant_1", "error": "failed to change tenant to tenant_1: " + errUnknownDatabase.Error()},
+	}, decodeLogRecords(t, buf))
+	assertLogRecords(t, []map[string]any{
+		{"level": "WARN", "msg": "failed to decide tenant", "error": nagaya.ErrNoTenantBound.Error()},
+		{"level": "ERROR", "msg": "failed to bind connection", "nagaya.tenant": "tenant_2", "error": "failed to change tenant to tenant_2: " + errUnknownDatabase.Error()},
+		{"level": "DEBUG", "msg": "bound connection", "nagaya.tenant": "tenant_2", "nagaya.request_id": "req_2"},
+		{"level": "DEBUG", "msg": "released connection", "nagaya.tenant": "tenant_2", "nagaya.request_id": "req_2"},
+	}, decodeLogRecords(t, doBuf))
+}
+
+func dropTime(_ []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return attr
+}
+
+func dropTimeAndLevel(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey {
+		return slog.Attr{}
+	}
+	return dropTime(groups, attr)
+}
+
+func decodeLogRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record map[string]any
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func assertLogRecords(t *testing.T, want, got []map[string]any) {
+	t.Helper()
+
+	if len(want) != len(got) {
+		t.Fatalf("the number of records:\n\twant: %d\n\t got: %d\n%#v", len(want), len(got), got)
+	}
+	for i := range want {
+		assertLogRecord(t, want[i], got[i])
+	}
+}
+
+func assertLogRecord(t *testing.T, want, got map[string]any) {
+	t.Helper()
+
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if !bytes.Equal(wantJSON, gotJSON) {
+		t.Errorf("log record:\n\twant: %s\n\t got: %s", wantJSON, gotJSON)
+	}
+}
@@ -0,0 +1,143 @@
+package nagaya
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// MetricBindDuration is the name of the histogram of the duration of [Nagaya.BindConnection] in seconds.
+	//
+	// It is recorded for each phase distinguished by [KeyPhase].
+	MetricBindDuration = "nagaya.bind_connection.duration"
+	// MetricBindFailures is the name of the counter of the failures of [Nagaya.BindConnection].
+	//
+	// The kind of the failure is distinguished by [KeyErrorKind].
+	MetricBindFailures = "nagaya.bind_connection.failures"
+	// MetricBoundConnections is the name of the up-down counter of the connections currently bound for the requests.
+	MetricBoundConnections = "nagaya.connections.bound"
+	// MetricTenantRequests is the name of the counter of the requests for each tenant.
+	//
+	// Only the requests that the connection is bound for are counted, and the failures are counted by [MetricBindFailures] instead.
+	// The tenant is distinguished by [KeyTenant] up to the limit given by [WithTenantMetricsLimit].
+	MetricTenantRequests = "nagaya.tenant.requests"
+)
+
+const (
+	// PhaseAcquire is the phase of [Nagaya.BindConnection] that obtains a connection from the DB.
+	PhaseAcquire = "acquire"
+	// PhaseSwitch is the phase of [Nagaya.BindConnection] that switches the connection to the tenant.
+	PhaseSwitch = "switch"
+)
+
+// The values of [KeyErrorKind] that tell why [Nagaya.BindConnection] failed.
+const (
+	ErrorKindNoRequestID   = "no_request_id"
+	ErrorKindInvalidTenant = "invalid_tenant"
+	ErrorKindResolveShard  = "resolve_shard"
+	ErrorKindRelocating    = "relocating"
+	ErrorKindAcquire       = "acquire"
+	ErrorKindSwitch        = "switch"
+	ErrorKindSwitchTimeout = "switch_timeout"
+	ErrorKindHook          = "hook"
+)
+
+// OtherTenants is the value of [KeyTenant] that the requests for the tenants over the limit are aggregated into.
+const OtherTenants = "_other"
+
+const defaultTenantMetricsLimit = 100
+
+func getMeter(meterProvider metric.MeterProvider) metric.Meter {
+	mp := meterProvider
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	return mp.Meter("github.com/aereal/nagaya.Nagaya")
+}
+
+type nagayaMetrics struct {
+	bindDuration   metric.Float64Histogram
+	bindFailures   metric.Int64Counter
+	boundConns     metric.Int64UpDownCounter
+	tenantRequests metric.Int64Counter
+	tenants        *tenantAttrLimiter
+}
+
+func newNagayaMetrics(mp metric.MeterProvider, tenantsLimit int) *nagayaMetrics {
+	meter := getMeter(mp)
+	if tenantsLimit <= 0 {
+		tenantsLimit = defaultTenantMetricsLimit
+	}
+	m := &nagayaMetrics{tenants: &tenantAttrLimiter{limit: tenantsLimit, seen: make(map[Tenant]struct{})}}
+	var err error
+	// the instruments are always usable even if the errors are returned, so just report them.
+	if m.bindDuration, err = meter.Float64Histogram(MetricBindDuration,
+		metric.WithDescription("The duration of binding a connection for the tenant."),
+		metric.WithUnit("s")); err != nil {
+		otel.Handle(err)
+	}
+	if m.bindFailures, err = meter.Int64Counter(MetricBindFailures,
+		metric.WithDescription("The number of failures of binding a connection for the tenant."),
+		metric.WithUnit("{failure}")); err != nil {
+		otel.Handle(err)
+	}
+	if m.boundConns, err = meter.Int64UpDownCounter(MetricBoundConnections,
+		metric.WithDescription("The number of connections currently bound for the requests."),
+		metric.WithUnit("{connection}")); err != nil {
+		otel.Handle(err)
+	}
+	if m.tenantRequests, err = meter.Int64Counter(MetricTenantRequests,
+		metric.WithDescription("The number of requests for each tenant."),
+		metric.WithUnit("{request}")); err != nil {
+		otel.Handle(err)
+	}
+	return m
+}
+
+func (m *nagayaMetrics) recordPhase(ctx context.Context, phase string, startedAt time.Time) {
+	m.bindDuration.Record(ctx, time.Since(startedAt).Seconds(), metric.WithAttributes(KeyPhase.String(phase)))
+}
+
+func (m *nagayaMetrics) recordFailure(ctx context.Context, kind string) {
+	m.bindFailures.Add(ctx, 1, metric.WithAttributes(KeyErrorKind.String(kind)))
+}
+
+func (m *nagayaMetrics) recordTenantRequest(ctx context.Context, tenant Tenant) {
+	m.tenantRequests.Add(ctx, 1, metric.WithAttributes(m.tenants.attr(tenant)))
+}
+
+// switchErrorKind tells whether the tenant switch failed due to its timeout.
+func switchErrorKind(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindSwitchTimeout
+	}
+	return ErrorKindSwitch
+}
+
+// tenantAttrLimiter bounds the cardinality of the tenant attribute.
+//
+// The first tenants up to the limit are recorded as is and the others are aggregated into [OtherTenants].
+type tenantAttrLimiter struct {
+	mux   sync.Mutex
+	seen  map[Tenant]struct{}
+	limit int
+}
+
+func (l *tenantAttrLimiter) attr(tenant Tenant) attribute.KeyValue {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if _, ok := l.seen[tenant]; ok {
+		return attrTenant(tenant)
+	}
+	if len(l.seen) >= l.limit {
+		return KeyTenant.String(OtherTenants)
+	}
+	l.seen[tenant] = struct{}{}
+	return attrTenant(tenant)
+}
@@ -0,0 +1,112 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/aereal/nagaya"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata/metricdatatest"
+)
+
+func TestNagaya_metrics(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	db := sql.OpenDB(stubConnector{})
+	t.Cleanup(func() { _ = db.Close() })
+	switcher := &failingSwitcher{}
+	ngy := nagaya.NewStd(db, nagaya.WithTenantSwitcher(switcher), nagaya.WithMeterProvider(mp), nagaya.WithTenantMetricsLimit(2))
+
+	ctx := t.Context()
+	for _, tenant := range []nagaya.Tenant{"tenant_1", "tenant_2", "tenant_1", "tenant_3"} {
+		reqID := "req_" + string(tenant)
+		if _, err := ngy.BindConnection(nagaya.ContextWithRequestID(ctx, reqID), tenant); err != nil {
+			t.Fatal(err)
+		}
+		ngy.ReleaseConnection(reqID)
+	}
+	if _, err := ngy.BindConnection(nagaya.ContextWithRequestID(ctx, "req_4"), "tenant_4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ngy.BindConnection(nagaya.ContextWithRequestID(ctx, "req_5"), "x;y"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := ngy.BindConnection(ctx, "tenant_1"); err == nil {
+		t.Fatal("expected an error")
+	}
+	switcher.err = errUnknownDatabase
+	if _, err := ngy.BindConnection(nagaya.ContextWithRequestID(ctx, "req_6"), "tenant_1"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+
+	metricdatatest.AssertEqual(t, metricdata.Metrics{
+		Name:        nagaya.MetricTenantRequests,
+		Description: "The number of requests for each tenant.",
+		Unit:        "{request}",
+		Data: metricdata.Sum[int64]{
+			Temporality: metricdata.CumulativeTemporality,
+			IsMonotonic: true,
+			DataPoints: []metricdata.DataPoint[int64]{
+				{Attributes: attribute.NewSet(nagaya.KeyTenant.String("tenant_1")), Value: 2},
+				{Attributes: attribute.NewSet(nagaya.KeyTenant.String("tenant_2")), Value: 1},
+				{Attributes: attribute.NewSet(nagaya.KeyTenant.String(nagaya.OtherTenants)), Value: 2},
+			},
+		},
+	}, metrics[nagaya.MetricTenantRequests], metricdatatest.IgnoreTimestamp())
+	metricdatatest.AssertEqual(t, metricdata.Metrics{
+		Name:        nagaya.MetricBindFailures,
+		Description: "The number of failures of binding a connection for the tenant.",
+		Unit:        "{failure}",
+		Data: metricdata.Sum[int64]{
+			Temporality: metricdata.CumulativeTemporality,
+			IsMonotonic: true,
+			DataPoints: []metricdata.DataPoint[int64]{
+				{Attributes: attribute.NewSet(nagaya.KeyErrorKind.String(nagaya.ErrorKindInvalidTenant)), Value: 1},
+				{Attributes: attribute.NewSet(nagaya.KeyErrorKind.String(nagaya.ErrorKindNoRequestID)), Value: 1},
+				{Attributes: attribute.NewSet(nagaya.KeyErrorKind.String(nagaya.ErrorKindSwitch)), Value: 1},
+			},
+		},
+	}, metrics[nagaya.MetricBindFailures], metricdatatest.IgnoreTimestamp())
+	metricdatatest.AssertEqual(t, metricdata.Metrics{
+		Name:        nagaya.MetricBoundConnections,
+		Description: "The number of connections currently bound for the requests.",
+		Unit:        "{connection}",
+		Data: metricdata.Sum[int64]{
+			Temporality: metricdata.CumulativeTemporality,
+			IsMonotonic: false,
+			DataPoints:  []metricdata.DataPoint[int64]{{Value: 1}},
+		},
+	}, metrics[nagaya.MetricBoundConnections], metricdatatest.IgnoreTimestamp())
+
+	hist, ok := metrics[nagaya.MetricBindDuration].Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("unexpected data: %#v", metrics[nagaya.MetricBindDuration].Data)
+	}
+	counts := make(map[string]uint64)
+	for _, dp := range hist.DataPoints {
+		phase, _ := dp.Attributes.Value(nagaya.KeyPhase)
+		counts[phase.AsString()] = dp.Count
+	}
+	for _, phase := range []string{nagaya.PhaseAcquire, nagaya.PhaseSwitch} {
+		if counts[phase] != 6 {
+			t.Errorf("histogram count of %s:\n\twant: %d\n\t got: %d", phase, 6, counts[phase])
+		}
+	}
+}
@@ -2,8 +2,7 @@ package nagaya
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -22,6 +21,7 @@ func failsToDetermineTenant(_ *http.Request) TenantDecisionResult {
 // Middleware returns a middleware function that determines target tenant and obtain the database connection against the tenant.
 //
 // The consumer must get the obtained connection via Nagaya.ObtainConnection method and use it to access the database.
+// If [WithTransaction] is given, the consumer can get the transaction via Nagaya.ObtainTx method instead.
 func Middleware[DB DBish, Conn Connish](n *Nagaya[DB, Conn], opts ...MiddlewareOption) func(http.Handler) http.Handler {
 	cfg := &middlewareConfig{bindConnectionCfg: new(bindConnectionConfig)}
 	for _, o := range opts {
@@ -34,23 +34,45 @@ func Middleware[DB DBish, Conn Connish](n *Nagaya[DB, Conn], opts ...MiddlewareO
 		cfg.reqIDGen = defaultIDGenerator
 	}
 	if cfg.errorHandler == nil {
-		cfg.errorHandler = jsonErrorHandler
+		cfg.errorHandler = JSONErrorHandler()
 	}
 	tracer := getTracer(cfg.tp)
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx, span := tracer.Start(r.Context(), "Nagaya.Middleware", trace.WithSpanKind(trace.SpanKindServer))
+			req := r
+			if cfg.rewriteRequest != nil {
+				req = cfg.rewriteRequest(r)
+			}
+			var d *doer[DB, Conn]
 			handler := func(ctx context.Context) error {
-				finishSpan(span, nil)
-				next.ServeHTTP(w, r.WithContext(ctx))
-				return nil
+				if !cfg.tx {
+					finishSpan(span, nil)
+					next.ServeHTTP(w, req.WithContext(ctx))
+					return nil
+				}
+				var tw *txResponseWriter
+				err := n.runTx(ctx, d.txBeginner(), cfg.txOptions, func(ctx context.Context, finisher *txFinisher) (bool, error) {
+					finishSpan(span, nil)
+					tw = &txResponseWriter{ResponseWriter: w, finisher: finisher}
+					next.ServeHTTP(tw, req.WithContext(ctx))
+					// the handler that writes nothing responds 200.
+					tw.decide(http.StatusOK)
+					return false, tw.commitErr
+				})
+				if err != nil && tw != nil && tw.sent() {
+					// the error response has been sent, so the error handler cannot tell the failure of the rollback to the client.
+					d.logger.LogAttrs(ctx, slog.LevelError, "failed to finish transaction", slog.Any("error", err))
+					return nil
+				}
+				return err
 			}
-			d := newDoer(n, handler, &optTenantDecisionResult{cfg.decideTenant(r)}, WithTimeout(cfg.bindConnectionCfg.changeTenantTimeout))
+			d = newDoer(n, handler, &optTenantDecisionResult{cfg.decideTenant(r)}, WithTimeout(cfg.bindConnectionCfg.changeTenantTimeout), &optTenantRegistry{cfg.registry}, &optRequestIDGenerator{cfg.reqIDGen}, &optLogger{cfg.logger})
 			if timeout := cfg.bindConnectionCfg.changeTenantTimeout; timeout != 0 {
 				d.bindConnectionOption = append(d.bindConnectionOption, WithTimeout(timeout))
 			}
 			if err := d.do(ctx); err != nil {
-				cfg.errorHandler(w, r, err)
+				cfg.errorHandler(w, r.WithContext(d.boundContext(r.Context())), err)
 				finishSpan(span, err)
 			}
 		})
@@ -133,23 +155,16 @@ func ContextWithRequestID(ctx context.Context, id string) context.Context {
 	return context.WithValue(ctx, reqIDCtxKey{}, id)
 }
 
-func reqIDFromContext(ctx context.Context) (string, bool) {
+// RequestIDFromContext extracts a request ID in the context.
+//
+// If no request ID is bound for the context, the second return value is a false.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
 	id, ok := ctx.Value(reqIDCtxKey{}).(string)
 	return id, ok
 }
 
 // ErrorHandler is a function that called if the error occurred.
+//
+// The context of the request conveys the tenant and the request ID if they have been decided before the error,
+// so that they can be taken by [TenantFromContext] and [RequestIDFromContext].
 type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
-
-func jsonErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
-	w.Header().Set("content-type", "application/json")
-	w.Header().Set("content-security-policy", "default-src 'none'")
-	w.Header().Set("x-content-type-options", "nosniff")
-	w.Header().Set("x-frame-options", "DENY")
-	status := http.StatusInternalServerError
-	if errors.Is(err, ErrNoConnectionBound) {
-		status = http.StatusBadRequest
-	}
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()}) //nolint:errcheck,errchkjson
-}
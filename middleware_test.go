@@ -67,22 +67,35 @@ func TestMiddleware(t *testing.T) {
 		},
 		{
 			name:             "ng/no tenant id header",
-			wantStatus:       http.StatusInternalServerError,
+			wantStatus:       http.StatusBadRequest,
 			wantErrorMessage: "no tenant bound for the context",
 			options:          []nagaya.MiddlewareOption{nagaya.DecideTenantFromHeader("tenant-id")},
 		},
 		{
 			name:             "ng/not configured how to get tenant",
-			wantStatus:       http.StatusInternalServerError,
+			wantStatus:       http.StatusBadRequest,
 			wantErrorMessage: "no tenant bound for the context",
 		},
 		{
 			name:             "ng/unknown tenant",
-			wantStatus:       http.StatusInternalServerError,
-			wantErrorMessage: "failed to change tenant to tenant_non_existent: Error 1049 (42000): Unknown database 'tenant_non_existent'",
+			wantStatus:       http.StatusNotFound,
+			wantErrorMessage: "failed to change tenant to tenant_non_existent: unknown tenant: tenant_non_existent",
 			options:          []nagaya.MiddlewareOption{nagaya.DecideTenantFromHeader("tenant-id")},
 			tenantIDHeader:   "tenant_non_existent",
 		},
+		{
+			name:             "ng/invalid tenant",
+			wantStatus:       http.StatusBadRequest,
+			wantErrorMessage: `invalid tenant "x; drop database y": contains disallowed character ';'`,
+			options:          []nagaya.MiddlewareOption{nagaya.DecideTenantFromHeader("tenant-id")},
+			tenantIDHeader:   "x; drop database y",
+		},
+		{
+			name:             "ng/no bearer token",
+			wantStatus:       http.StatusUnauthorized,
+			wantErrorMessage: "failed to verify token: no bearer token",
+			options:          []nagaya.MiddlewareOption{nagaya.WithDecideTenantFn(nagaya.DecideTenantFromJWT(nagaya.WithHMACSecret([]byte("s3cr3t"))))},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -208,14 +221,158 @@ const envTestDBDSN = "TEST_DB_DSN"
 
 var errDSNRequired = fmt.Errorf("%s is required", envTestDBDSN)
 
-func newMySQLNagayaForTesting() (*nagaya.Nagaya[*sql.DB, *sql.Conn], error) {
+func newMySQLNagayaForTesting(opts ...nagaya.NewOption) (*nagaya.Nagaya[*sql.DB, *sql.Conn], error) {
+	db, err := openMySQLForTesting()
+	if err != nil {
+		return nil, err
+	}
+	return nagaya.NewStd(db, opts...), nil
+}
+
+func openMySQLForTesting() (*sql.DB, error) {
 	dsn := os.Getenv(envTestDBDSN)
 	if dsn == "" {
 		return nil, errDSNRequired
 	}
-	db, err := sql.Open("mysql", dsn)
+	return sql.Open("mysql", dsn)
+}
+
+func TestMiddleware_pathTenant(t *testing.T) {
+	t.Parallel()
+	ngy, err := newMySQLNagayaForTesting()
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dbName, err := getCurrentDBName(r.Context(), ngy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"path": r.URL.Path, "db": dbName}) //nolint:errcheck,errchkjson
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/t/", nagaya.Middleware(ngy, nagaya.DecideTenantFromPathPrefix("/t/"))(handler))
+	mux.Handle("/v/{tenant}/", nagaya.Middleware(ngy, nagaya.DecideTenantFromPathValue("tenant"))(handler))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(func() { srv.Close() })
+
+	testCases := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantPath   string
+		wantDB     string
+	}{
+		{name: "path prefix", path: "/t/tenant_1/users/1", wantStatus: http.StatusOK, wantPath: "/users/1", wantDB: "tenant_1"},
+		{name: "path prefix without rest", path: "/t/tenant_2", wantStatus: http.StatusOK, wantPath: "/", wantDB: "tenant_2"},
+		{name: "path prefix without tenant", path: "/t/", wantStatus: http.StatusBadRequest},
+		{name: "path value", path: "/v/tenant_3/users/1", wantStatus: http.StatusOK, wantPath: "/users/1", wantDB: "tenant_3"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("status:\n\twant: %d\n\t got: %d", tc.wantStatus, resp.StatusCode)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			var body map[string]string
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body["path"] != tc.wantPath {
+				t.Errorf("path:\n\twant: %s\n\t got: %s", tc.wantPath, body["path"])
+			}
+			if body["db"] != tc.wantDB {
+				t.Errorf("db:\n\twant: %s\n\t got: %s", tc.wantDB, body["db"])
+			}
+		})
+	}
+}
+
+func TestMiddleware_pathTenantOptions(t *testing.T) {
+	t.Parallel()
+
+	header := nagaya.FirstOf(nagaya.HeaderTenant("tenant-id"), nagaya.PathPrefixTenant("/t/"))
+	testCases := []struct {
+		name       string
+		options    []nagaya.MiddlewareOption
+		header     string
+		wantTenant nagaya.Tenant
+		wantPath   string
+	}{
+		{name: "shorthand", options: []nagaya.MiddlewareOption{nagaya.DecideTenantFromPathPrefix("/t/")}, wantTenant: "tenant_1", wantPath: "/users/1"},
+		{name: "decision only", options: []nagaya.MiddlewareOption{nagaya.WithDecideTenantFn(nagaya.PathPrefixTenant("/t/"))}, wantTenant: "tenant_1", wantPath: "/t/tenant_1/users/1"},
+		{name: "strip before decision", options: []nagaya.MiddlewareOption{nagaya.WithStripPathPrefix("/t/"), nagaya.WithDecideTenantFn(header)}, header: "tenant_2", wantTenant: "tenant_2", wantPath: "/users/1"},
+		{name: "decision before strip", options: []nagaya.MiddlewareOption{nagaya.WithDecideTenantFn(header), nagaya.WithStripPathPrefix("/t/")}, wantTenant: "tenant_1", wantPath: "/users/1"},
+		{name: "decision after shorthand", options: []nagaya.MiddlewareOption{nagaya.DecideTenantFromPathPrefix("/t/"), nagaya.WithDecideTenantFn(header)}, header: "tenant_2", wantTenant: "tenant_2", wantPath: "/users/1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}))
+			var (
+				gotTenant nagaya.Tenant
+				gotPath   string
+			)
+			handler := nagaya.Middleware(ngy, tc.options...)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				gotTenant, _ = nagaya.TenantFromContext(r.Context())
+				gotPath = r.URL.Path
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/t/tenant_1/users/1", nil)
+			if tc.header != "" {
+				req.Header.Set("tenant-id", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status:\n\twant: %d\n\t got: %d (%s)", http.StatusOK, rec.Code, rec.Body)
+			}
+			if gotTenant != tc.wantTenant {
+				t.Errorf("tenant:\n\twant: %s\n\t got: %s", tc.wantTenant, gotTenant)
+			}
+			if gotPath != tc.wantPath {
+				t.Errorf("path:\n\twant: %s\n\t got: %s", tc.wantPath, gotPath)
+			}
+		})
+	}
+}
+
+func TestMiddleware_withRequestIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}))
+	var (
+		gotReqID string
+		gotOK    bool
+	)
+	handler := nagaya.Middleware(ngy,
+		nagaya.DecideTenantFromHeader("tenant-id"),
+		nagaya.WithRequestIDGenerator(nagaya.RequestIDGeneratorFunc(func() (string, error) { return "req_custom", nil })),
+	)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		gotReqID, gotOK = nagaya.RequestIDFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("tenant-id", "tenant_1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status:\n\twant: %d\n\t got: %d (%s)", http.StatusOK, rec.Code, rec.Body)
+	}
+	if !gotOK || gotReqID != "req_custom" {
+		t.Errorf("RequestIDFromContext:\n\twant: req_custom\n\t got: %q (%t)", gotReqID, gotOK)
 	}
-	return nagaya.NewStd(db), nil
 }
@@ -0,0 +1,274 @@
+package nagaya
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultMigrationTable = "nagaya_schema_migrations"
+
+// Migration is a versioned SQL script applied to each tenant.
+type Migration struct {
+	// Name is a file name of the migration.
+	Name       string
+	statements []string
+	// Version is a version of the migration taken from the numeric prefix of the file name.
+	Version int64
+}
+
+// Migrator applies the migrations to all tenants in the [TenantRegistry].
+//
+// It tracks the applied versions in the bookkeeping table of each tenant.
+type Migrator[DB DBish, Conn Connish] struct {
+	n        *Nagaya[DB, Conn]
+	registry TenantRegistry
+	// table is the name of the bookkeeping table quoted for the dialect.
+	table           string
+	migrations      []*Migration
+	doOpts          []DoOption
+	concurrency     int
+	continueOnError bool
+}
+
+// NewMigrator returns a new [Migrator] that reads the migrations from the fsys.
+//
+// The migrations are the files that have .sql extension in the root of the fsys and their names must start with a version number
+// such as 0001_create_users.sql. The migrations are applied in the ascending order of the versions.
+// The statements in the files are separated in the same way as [WithSchemaTemplate].
+func NewMigrator[DB DBish, Conn Connish](n *Nagaya[DB, Conn], registry TenantRegistry, fsys fs.FS, opts ...MigratorOption) (*Migrator[DB, Conn], error) {
+	cfg := &migratorConfig{concurrency: 1, table: defaultMigrationTable}
+	for _, o := range opts {
+		o.applyMigratorOption(cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+	migrations, err := readMigrations(fsys, n.standardStrings())
+	if err != nil {
+		return nil, err
+	}
+	table := quoteMySQLIdentifier(cfg.table)
+	if _, ok := n.switcher.(*PostgreSQLTenantSwitcher); ok {
+		table = quotePostgreSQLIdentifier(cfg.table)
+	}
+	m := &Migrator[DB, Conn]{
+		n:               n,
+		registry:        registry,
+		table:           table,
+		migrations:      migrations,
+		doOpts:          cfg.doOpts,
+		concurrency:     cfg.concurrency,
+		continueOnError: cfg.continueOnError,
+	}
+	return m, nil
+}
+
+func readMigrations(fsys fs.FS, standardStrings bool) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]*Migration, 0, len(entries))
+	versions := make(map[int64]string, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+		prefix, _, _ := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, &InvalidMigrationError{name: name, reason: "the name must start with a version number"}
+		}
+		if other, ok := versions[version]; ok {
+			return nil, &InvalidMigrationError{name: name, reason: fmt.Sprintf("the version is duplicated with %s", other)}
+		}
+		versions[version] = name
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, &Migration{Name: name, Version: version, statements: splitStatements(string(content), standardStrings)})
+	}
+	slices.SortFunc(migrations, func(a, b *Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// Migrations returns the migrations in the order of application.
+func (m *Migrator[DB, Conn]) Migrations() []*Migration {
+	return slices.Clone(m.migrations)
+}
+
+// MigrationReport is a result of applying migrations to the tenants.
+type MigrationReport struct {
+	// Results are the results of each tenant in the order of [TenantRegistry.List].
+	Results []*TenantMigrationResult
+}
+
+// Err returns the errors of all failed tenants joined by [errors.Join].
+func (r *MigrationReport) Err() error {
+	errs := make([]error, 0, len(r.Results))
+	for _, ret := range r.Results {
+		if ret.Err != nil {
+			errs = append(errs, ret.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TenantMigrationResult is a result of applying migrations to a tenant.
+type TenantMigrationResult struct {
+	// Err is an error occurred while migrating the tenant.
+	Err    error
+	Tenant Tenant
+	// Applied is versions newly applied to the tenant.
+	Applied []int64
+	// Skipped reports whether the tenant is not migrated because the other tenant failed.
+	Skipped bool
+}
+
+// Migrate applies the pending migrations to all tenants in the registry.
+//
+// Unless [WithContinueOnError] is given, the tenants not started yet are skipped after the first failure;
+// the tenants in progress are not interrupted and run to the end.
+// The returned error is the same as [MigrationReport.Err].
+func (m *Migrator[DB, Conn]) Migrate(ctx context.Context) (_ *MigrationReport, err error) {
+	ctx, span := m.n.tracer.Start(ctx, "Nagaya.Migrator.Migrate")
+	defer func() { finishSpan(span, err) }()
+
+	tenants, err := m.registry.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// stop only prevents starting new tenants; cancelling ctx would abort the DDL of the tenants in progress.
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	stopped := func() bool {
+		select {
+		case <-stop:
+			return true
+		default:
+			return ctx.Err() != nil
+		}
+	}
+
+	report := &MigrationReport{Results: make([]*TenantMigrationResult, len(tenants))}
+	sem := make(chan struct{}, m.concurrency)
+	var wg sync.WaitGroup
+	for i, tenant := range tenants {
+		ret := &TenantMigrationResult{Tenant: tenant}
+		report.Results[i] = ret
+		select {
+		case sem <- struct{}{}:
+		case <-stop:
+		case <-ctx.Done():
+		}
+		if stopped() {
+			ret.Skipped = true
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ret.Applied, ret.Err = m.MigrateTenant(ctx, tenant)
+			if ret.Err != nil && !m.continueOnError {
+				stopOnce.Do(func() { close(stop) })
+			}
+		}()
+	}
+	wg.Wait()
+	return report, report.Err()
+}
+
+// MigrateTenant applies the pending migrations to the tenant and returns the versions newly applied.
+//
+// With [PostgreSQLTenantSwitcher], each migration and its record in the bookkeeping table run in one transaction,
+// so a failed migration leaves nothing.
+// MySQL commits DDL implicitly, so the statements of a failed migration that have run are left applied
+// without the record and the migration runs again from the first statement next time;
+// write such migrations idempotently or in a single statement.
+func (m *Migrator[DB, Conn]) MigrateTenant(ctx context.Context, tenant Tenant) (applied []int64, err error) {
+	ctx, span := m.n.tracer.Start(ctx, "Nagaya.Migrator.MigrateTenant", trace.WithAttributes(attrTenant(tenant)))
+	defer func() { finishSpan(span, err) }()
+
+	handler := func(ctx context.Context) error {
+		conn, err := m.n.ObtainConnection(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("create table if not exists %s (version bigint not null primary key)", m.table)); err != nil {
+			return &MigrateTenantError{err: err, tenant: tenant}
+		}
+		done, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return &MigrateTenantError{err: err, tenant: tenant}
+		}
+		for _, migration := range m.migrations {
+			if done[migration.Version] {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return &MigrateTenantError{err: err, tenant: tenant, version: migration.Version}
+			}
+			applied = append(applied, migration.Version)
+		}
+		return nil
+	}
+	opts := append(slices.Clone(m.doOpts), WithTenantDecisionResult(&TenantDecisionResultChangeTenant{Tenant: tenant}))
+	err = Do(ctx, m.n, handler, opts...)
+	span.SetAttributes(attribute.Int("nagaya.migration.applied_count", len(applied)))
+	return applied, err
+}
+
+// apply runs the statements of the migration and records its version, in a transaction if the dialect supports transactional DDL.
+func (m *Migrator[DB, Conn]) apply(ctx context.Context, conn Conn, migration *Migration) error {
+	run := func(ctx context.Context, execer Execer) error {
+		for _, stmt := range migration.statements {
+			if _, err := execer.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		_, err := execer.ExecContext(ctx, fmt.Sprintf("insert into %s (version) values (%d)", m.table, migration.Version))
+		return err
+	}
+	if _, ok := m.n.switcher.(*PostgreSQLTenantSwitcher); !ok {
+		return run(ctx, conn)
+	}
+	return m.n.runTx(ctx, conn, nil, func(ctx context.Context, _ *txFinisher) (bool, error) {
+		tx, _ := TxFromContext(ctx)
+		err := run(ctx, tx)
+		return err == nil, err
+	})
+}
+
+func (m *Migrator[DB, Conn]) appliedVersions(ctx context.Context, conn Conn) (map[int64]bool, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("select version from %s", m.table))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	versions := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
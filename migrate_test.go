@@ -0,0 +1,221 @@
+package nagaya_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+func TestMigrator_Migrate(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newMySQLNagayaForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := t.Context()
+	tenants := createTenantsForTesting(ctx, t, ngy, 2)
+	infos := make([]nagaya.TenantInfo, len(tenants))
+	for i, tenant := range tenants {
+		infos[i] = nagaya.TenantInfo{Tenant: tenant}
+	}
+	registry := nagaya.NewInMemoryTenantRegistry(infos...)
+	fsys := fstest.MapFS{
+		"0001_create_users.sql": &fstest.MapFile{Data: []byte("create table users (id bigint not null primary key);")},
+		"0002_add_name.sql":     &fstest.MapFile{Data: []byte("alter table users add column name varchar(64);\ninsert into users (id, name) values (1, 'a;b');")},
+		"README.md":             &fstest.MapFile{Data: []byte("not a migration")},
+	}
+	migrator, err := nagaya.NewMigrator(ngy, registry, fsys, nagaya.WithConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := migrator.Migrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ret := range report.Results {
+		if ret.Tenant != tenants[i] {
+			t.Errorf("Results[%d].Tenant: want=%s got=%s", i, tenants[i], ret.Tenant)
+		}
+		if want := []int64{1, 2}; !slices.Equal(ret.Applied, want) {
+			t.Errorf("Results[%d].Applied: want=%v got=%v", i, want, ret.Applied)
+		}
+	}
+
+	report, err = migrator.Migrate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ret := range report.Results {
+		if len(ret.Applied) != 0 {
+			t.Errorf("Results[%d].Applied must be empty on the second run but got %v", i, ret.Applied)
+		}
+	}
+
+	fsys["0003_broken.sql"] = &fstest.MapFile{Data: []byte("this is not SQL;")}
+	testCases := []struct {
+		name        string
+		opts        []nagaya.MigratorOption
+		wantSkipped []bool
+	}{
+		{name: "stop on first error", wantSkipped: []bool{false, true}},
+		{name: "continue on error", opts: []nagaya.MigratorOption{nagaya.WithContinueOnError()}, wantSkipped: []bool{false, false}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrator, err := nagaya.NewMigrator(ngy, registry, fsys, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report, err := migrator.Migrate(t.Context())
+			var migrateErr *nagaya.MigrateTenantError
+			if !errors.As(err, &migrateErr) {
+				t.Fatalf("expected MigrateTenantError but got: %v", err)
+			}
+			if migrateErr.Version() != 3 {
+				t.Errorf("unexpected failed version: %d", migrateErr.Version())
+			}
+			for i, ret := range report.Results {
+				if ret.Skipped != tc.wantSkipped[i] {
+					t.Errorf("Results[%d].Skipped: want=%v got=%v", i, tc.wantSkipped[i], ret.Skipped)
+				}
+				if !ret.Skipped && ret.Err == nil {
+					t.Errorf("Results[%d].Err must not be nil", i)
+				}
+			}
+		})
+	}
+}
+
+// gatedSwitcher fails to switch to tenant_1 and holds the switch to tenant_2 until tenant_1 has failed.
+type gatedSwitcher struct {
+	nagaya.MySQLTenantSwitcher
+	failed    chan struct{}
+	cancelled chan bool
+}
+
+func (s *gatedSwitcher) Switch(ctx context.Context, _ nagaya.Execer, tenant nagaya.Tenant) error {
+	switch tenant {
+	case "tenant_1":
+		defer close(s.failed)
+		return errors.New("oops")
+	case "tenant_2":
+		<-s.failed
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+		s.cancelled <- errors.Is(ctx.Err(), context.Canceled)
+	}
+	return nil
+}
+
+func TestMigrator_Migrate_stopKeepsInFlightTenants(t *testing.T) {
+	t.Parallel()
+
+	switcher := &gatedSwitcher{failed: make(chan struct{}), cancelled: make(chan bool, 1)}
+	ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithTenantSwitcher(switcher))
+	registry := nagaya.NewInMemoryTenantRegistry(nagaya.TenantInfo{Tenant: "tenant_1"}, nagaya.TenantInfo{Tenant: "tenant_2"}, nagaya.TenantInfo{Tenant: "tenant_3"})
+	fsys := fstest.MapFS{"0001_create_users.sql": &fstest.MapFile{Data: []byte("create table users (id bigint not null primary key);")}}
+	migrator, err := nagaya.NewMigrator(ngy, registry, fsys, nagaya.WithConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, _ := migrator.Migrate(t.Context())
+	if <-switcher.cancelled {
+		t.Error("the tenant in progress must not be cancelled by the failure of another tenant")
+	}
+	for i, wantSkipped := range []bool{false, false, true} {
+		if got := report.Results[i].Skipped; got != wantSkipped {
+			t.Errorf("Results[%d].Skipped: want=%v got=%v", i, wantSkipped, got)
+		}
+	}
+}
+
+func TestMigrator_MigrateTenant_postgresTransaction(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newPostgreSQLNagayaForTesting(nagaya.WithTenantSwitcher(&nagaya.PostgreSQLTenantSwitcher{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := t.Context()
+	tenant := createTenantsForTesting(ctx, t, ngy, 1)[0]
+	fsys := fstest.MapFS{"0001_broken.sql": &fstest.MapFile{Data: []byte("create table users (id bigint not null primary key);\nthis is not SQL;")}}
+	migrator, err := nagaya.NewMigrator(ngy, nagaya.NewInMemoryTenantRegistry(), fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.MigrateTenant(ctx, tenant); err == nil {
+		t.Fatal("expected the broken migration fails")
+	}
+	var count int
+	err = nagaya.Do(ctx, ngy, func(ctx context.Context) error {
+		conn, err := ngy.ObtainConnection(ctx)
+		if err != nil {
+			return err
+		}
+		return conn.QueryRowContext(ctx, "select count(*) from information_schema.tables where table_schema = $1 and table_name = 'users'", string(tenant)).Scan(&count)
+	}, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: tenant}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Error("the statements of the failed migration must be rolled back")
+	}
+}
+
+func TestNewMigrator_invalidMigrations(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "no version",
+			fsys: fstest.MapFS{"create_users.sql": &fstest.MapFile{}},
+		},
+		{
+			name: "duplicated version",
+			fsys: fstest.MapFS{"1_create_users.sql": &fstest.MapFile{}, "0001_create_posts.sql": &fstest.MapFile{}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := nagaya.NewMigrator(nagaya.NewStd(nil), nagaya.NewInMemoryTenantRegistry(), tc.fsys)
+			var invalidErr *nagaya.InvalidMigrationError
+			if !errors.As(err, &invalidErr) {
+				t.Errorf("expected InvalidMigrationError but got: %v", err)
+			}
+		})
+	}
+}
+
+func createTenantsForTesting(ctx context.Context, t *testing.T, ngy interface {
+	CreateTenant(context.Context, nagaya.Tenant, ...nagaya.CreateTenantOption) error
+	DropTenant(context.Context, nagaya.Tenant) error
+}, n int,
+) []nagaya.Tenant {
+	t.Helper()
+
+	tenants := make([]nagaya.Tenant, n)
+	for i := range tenants {
+		tenant := nagaya.Tenant(fmt.Sprintf("tenant_test_%d_%d", time.Now().UnixNano(), i))
+		if err := ngy.CreateTenant(ctx, tenant); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = ngy.DropTenant(context.WithoutCancel(ctx), tenant) })
+		tenants[i] = tenant
+	}
+	return tenants
+}
@@ -3,8 +3,13 @@ package nagaya
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
 	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel/trace"
 )
@@ -36,8 +41,54 @@ func New[DB DBish, Conn Connish](db DB, getConn GetConnFn[DB, Conn], opts ...New
 		o.applyNewOption(cfg)
 	}
 	tracer := getTracer(cfg.tp)
+	switcher := cfg.switcher
+	if switcher == nil {
+		switcher = new(MySQLTenantSwitcher)
+	}
+	rule := cfg.rule
+	if rule == nil {
+		rule = defaultTenantRule
+	}
+	logger := cfg.logger
+	if logger == nil {
+		logger = discardLogger
+	}
+	replicaPolicy := cfg.replicaPolicy
+	if replicaPolicy == nil {
+		replicaPolicy = RoundRobin()
+	}
+	shards := newShards[DB](cfg.shards)
+	replicas := newReplicas(cfg.replicas, shards)
+	replicaDBs := make(map[string][]DBish, len(replicas))
+	for shard, dbs := range replicas {
+		for _, db := range dbs {
+			replicaDBs[shard] = append(replicaDBs[shard], db)
+		}
+	}
+	shardResolver := cfg.shardResolver
+	if shardResolver == nil {
+		shardResolver = HashShardResolver(slices.Sorted(maps.Keys(shards))...)
+	}
 
-	n := &Nagaya[DB, Conn]{db: db, conns: make(map[string]Conn), getConn: getConn, tracer: tracer}
+	n := &Nagaya[DB, Conn]{
+		db:             db,
+		replicas:       replicas,
+		replicaDBs:     replicaDBs,
+		replicaPolicy:  replicaPolicy,
+		shards:         shards,
+		shardResolver:  shardResolver,
+		relocationWait: cfg.relocationWait,
+		conns:          make(map[string]Conn),
+		readConns:      make(map[string]readConn[Conn]),
+		bindings:       make(map[string]binding),
+		getConn:        getConn,
+		tracer:         tracer,
+		metrics:        newNagayaMetrics(cfg.mp, cfg.tenantMetricsLimit),
+		hooks:          newHooks[Conn](cfg.hooks),
+		logger:         logger,
+		switcher:       switcher,
+		rule:           rule,
+	}
 	return n
 }
 
@@ -50,11 +101,28 @@ func getConnStd(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
 }
 
 type Nagaya[DB DBish, Conn Connish] struct {
-	tracer  trace.Tracer
-	db      DB
-	conns   map[string]Conn
-	getConn GetConnFn[DB, Conn]
-	mux     sync.RWMutex
+	tracer   trace.Tracer
+	metrics  *nagayaMetrics
+	hooks    *hooks[Conn]
+	logger   *slog.Logger
+	switcher TenantSwitcher
+	rule     *TenantRule
+	db       DB
+	conns    map[string]Conn
+	// replicas are keyed by the shard names; replicaDBs holds the same DBs to pass them to the ReplicaPolicy.
+	replicas      map[string][]DB
+	replicaDBs    map[string][]DBish
+	replicaPolicy ReplicaPolicy
+	readConns     map[string]readConn[Conn]
+	// bindings holds how the connection is bound for each request.
+	bindings      map[string]binding
+	shards        map[string]DB
+	shardResolver ShardResolver
+	// relocations holds the tenants under relocation that BindConnection waits for up to relocationWait.
+	relocations    relocationGate
+	relocationWait time.Duration
+	getConn        GetConnFn[DB, Conn]
+	mux            sync.RWMutex
 }
 
 // ObtainConnection returns a database connection bound to the current tenant.
@@ -63,9 +131,9 @@ type Nagaya[DB DBish, Conn Connish] struct {
 // Almost users just use [Middleware] that calls [BindConnection].
 func (n *Nagaya[DB, Conn]) ObtainConnection(ctx context.Context) (conn Conn, err error) {
 	ctx, span := n.tracer.Start(ctx, "Nagaya.ObtainConnection")
-	defer finishSpan(span, err)
+	defer func() { finishSpan(span, err) }()
 
-	reqID, ok := reqIDFromContext(ctx)
+	reqID, ok := RequestIDFromContext(ctx)
 	if !ok {
 		err = ErrNoConnectionBound
 		return
@@ -86,7 +154,7 @@ func (n *Nagaya[DB, Conn]) ObtainConnection(ctx context.Context) (conn Conn, err
 // Usually the users should use [Middleware].
 func (n *Nagaya[DB, Conn]) BindConnection(ctx context.Context, tenant Tenant, opts ...BindConnectionOption) (c Conn, err error) {
 	ctx, span := n.tracer.Start(ctx, "Nagaya.BindConnection", trace.WithAttributes(attrTenant(tenant)))
-	defer finishSpan(span, err)
+	defer func() { finishSpan(span, err) }()
 
 	var cfg bindConnectionConfig
 	for _, o := range opts {
@@ -95,32 +163,173 @@ func (n *Nagaya[DB, Conn]) BindConnection(ctx context.Context, tenant Tenant, op
 	if cfg.changeTenantTimeout == 0 {
 		cfg.changeTenantTimeout = defaultChangeTenantTimeout
 	}
+	if cfg.logger == nil {
+		cfg.logger = n.logger
+	}
+
+	event := &HookEvent[Conn]{Tenant: tenant}
+	defer func() {
+		if err != nil {
+			cfg.logger.LogAttrs(ctx, errorLogLevel(err), "failed to bind connection", logAttrTenant(tenant), slog.Any("error", err))
+			n.hooks.bindFailed(ctx, event, err)
+		}
+	}()
 
-	requestID, ok := reqIDFromContext(ctx)
+	requestID, ok := RequestIDFromContext(ctx)
 	if !ok {
+		n.metrics.recordFailure(ctx, ErrorKindNoRequestID)
 		return c, ErrNoConnectionBound
 	}
+	event.RequestID = requestID
 	span.SetAttributes(attrRequestID(requestID))
-	conn, err := n.getConn(ctx, n.db)
+	if err := n.rule.Validate(tenant); err != nil {
+		n.metrics.recordFailure(ctx, ErrorKindInvalidTenant)
+		return c, err
+	}
+	if err := n.relocations.wait(ctx, tenant, n.relocationWait); err != nil {
+		n.metrics.recordFailure(ctx, ErrorKindRelocating)
+		return c, err
+	}
+	db, shard, err := n.dbFor(ctx, tenant)
 	if err != nil {
-		return c, &ObtainConnectionError{err: err}
+		n.metrics.recordFailure(ctx, ErrorKindResolveShard)
+		return c, err
 	}
-	exCtx, cancel := context.WithTimeout(ctx, cfg.changeTenantTimeout)
-	defer cancel()
-	if _, err := conn.ExecContext(exCtx, fmt.Sprintf("use %s", tenant)); err != nil {
-		return c, &ChangeTenantError{err: err, tenant: tenant}
+	if shard != "" {
+		span.SetAttributes(KeyShard.String(shard))
+	}
+	conn, err := n.bind(ctx, db, event, cfg.changeTenantTimeout)
+	if err != nil {
+		return c, err
 	}
 	n.mux.Lock()
 	n.conns[requestID] = conn
+	n.bindings[requestID] = binding{tenant: tenant, shard: shard, logger: cfg.logger, timeout: cfg.changeTenantTimeout}
 	n.mux.Unlock()
+	n.metrics.boundConns.Add(ctx, 1)
+	n.metrics.recordTenantRequest(ctx, tenant)
+	cfg.logger.LogAttrs(ctx, slog.LevelDebug, "bound connection", logAttrTenant(tenant), logAttrRequestID(requestID))
+	return conn, nil
+}
+
+// bind obtains a connection from the db and switches it to the tenant of the event with the hooks.
+//
+// If it fails after the connection is obtained, the connection is discarded.
+func (n *Nagaya[DB, Conn]) bind(ctx context.Context, db DB, event *HookEvent[Conn], timeout time.Duration) (c Conn, err error) {
+	startedAt := time.Now()
+	conn, err := n.getConn(ctx, db)
+	n.metrics.recordPhase(ctx, PhaseAcquire, startedAt)
+	if err != nil {
+		n.metrics.recordFailure(ctx, ErrorKindAcquire)
+		return c, &ObtainConnectionError{err: err}
+	}
+	event.Conn = conn
+	defer func() {
+		if err != nil {
+			// the connection may be switched or set up partially, so it must not be returned to the pool.
+			discardConnection(conn)
+			_ = conn.Close()
+		}
+	}()
+	if err := n.hooks.run(ctx, HookBeforeBind, n.hooks.beforeBind, event); err != nil {
+		n.metrics.recordFailure(ctx, ErrorKindHook)
+		return c, err
+	}
+	startedAt = time.Now()
+	err = switchTenant(ctx, n.switcher, conn, event.Tenant, timeout)
+	n.metrics.recordPhase(ctx, PhaseSwitch, startedAt)
+	if err != nil {
+		n.metrics.recordFailure(ctx, switchErrorKind(err))
+		return c, err
+	}
+	if err := n.hooks.run(ctx, HookAfterBind, n.hooks.afterBind, event); err != nil {
+		n.metrics.recordFailure(ctx, ErrorKindHook)
+		return c, err
+	}
 	return conn, nil
 }
 
 // ReleaseConnection marks the current request's connection is ready to discard.
 //
-// This method does not call [sql.Conn.Close], it is caller's responsibility.
+// This method does not call [Nagaya.RestoreConnection] nor [sql.Conn.Close], it is caller's responsibility.
 func (n *Nagaya[DB, Conn]) ReleaseConnection(requestID string) {
 	n.mux.Lock()
 	defer n.mux.Unlock()
+	if _, ok := n.conns[requestID]; !ok {
+		return
+	}
+	b := n.bindings[requestID]
 	delete(n.conns, requestID)
+	delete(n.bindings, requestID)
+	n.metrics.boundConns.Add(context.Background(), -1)
+	b.logger.LogAttrs(context.Background(), slog.LevelDebug, "released connection", logAttrTenant(b.tenant), logAttrRequestID(requestID))
+}
+
+// binding describes how the connection is bound for the request.
+type binding struct {
+	tenant Tenant
+	shard  string
+	// logger is the one given to BindConnection, which is used until the connection is released.
+	logger *slog.Logger
+	// timeout is the one given to BindConnection, which is also applied to the read connection.
+	timeout time.Duration
+}
+
+// loggerFor returns the logger given to BindConnection for the request, or the logger of the Nagaya if not bound.
+func (n *Nagaya[DB, Conn]) loggerFor(requestID string) *slog.Logger {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	if b, ok := n.bindings[requestID]; ok {
+		return b.logger
+	}
+	return n.logger
+}
+
+// RestoreConnection resets the tenant of the connection so that it can be returned to the pool safely.
+//
+// The [BeforeRelease] hooks run before the reset with the tenant and the request ID in the context.
+// If the hooks or the [TenantSwitcher] fail to reset the connection, the connection is marked as broken
+// so that [database/sql] discards it on [sql.Conn.Close] instead of returning it to the pool.
+func (n *Nagaya[DB, Conn]) RestoreConnection(ctx context.Context, conn Conn) (err error) {
+	ctx, span := n.tracer.Start(ctx, "Nagaya.RestoreConnection")
+	defer func() { finishSpan(span, err) }()
+
+	event := &HookEvent[Conn]{Conn: conn}
+	event.Tenant, _ = TenantFromContext(ctx)
+	event.RequestID, _ = RequestIDFromContext(ctx)
+	hookCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultChangeTenantTimeout)
+	defer cancel()
+	logger := n.loggerFor(event.RequestID)
+	if err := n.hooks.run(hookCtx, HookBeforeRelease, n.hooks.beforeRelease, event); err != nil {
+		discardConnection(conn)
+		logger.LogAttrs(ctx, slog.LevelError, "failed to clean up connection; discarded", slog.Any("error", err))
+		return err
+	}
+	return n.resetConnection(ctx, conn, logger)
+}
+
+// resetConnection resets the tenant of the connection without the hooks.
+func (n *Nagaya[DB, Conn]) resetConnection(ctx context.Context, conn Conn, logger *slog.Logger) error {
+	resetCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), defaultChangeTenantTimeout)
+	defer cancel()
+	if err := n.switcher.Reset(resetCtx, conn); err != nil {
+		discardConnection(conn)
+		if errors.Is(err, ErrNoDefaultTenant) {
+			return nil
+		}
+		logger.LogAttrs(ctx, slog.LevelError, "failed to restore connection; discarded", slog.Any("error", err))
+		return &ResetTenantError{err: err}
+	}
+	return nil
+}
+
+type rawConn interface {
+	Raw(func(driverConn any) error) error
+}
+
+// discardConnection tells database/sql not to return the connection to the pool.
+func discardConnection(conn Connish) {
+	if rc, ok := conn.(rawConn); ok {
+		_ = rc.Raw(func(any) error { return driver.ErrBadConn })
+	}
 }
@@ -1,14 +1,33 @@
 package nagaya
 
 import (
+	"crypto"
+	"database/sql"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/netip"
+	"strings"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type newConfig struct {
-	tp trace.TracerProvider
+	tp                 trace.TracerProvider
+	mp                 metric.MeterProvider
+	logger             *slog.Logger
+	switcher           TenantSwitcher
+	rule               *TenantRule
+	tenantMetricsLimit int
+	hooks              []any
+	replicas           []any
+	replicaPolicy      ReplicaPolicy
+	shards             []any
+	shardResolver      ShardResolver
+	relocationWait     time.Duration
 }
 
 type NewOption interface {
@@ -19,7 +38,12 @@ type middlewareConfig struct {
 	tp                trace.TracerProvider
 	reqIDGen          RequestIDGenerator
 	decideTenant      DecideRequestTenantFunc
+	rewriteRequest    func(*http.Request) *http.Request
 	errorHandler      ErrorHandler
+	registry          TenantRegistry
+	logger            *slog.Logger
+	tx                bool
+	txOptions         *sql.TxOptions
 	bindConnectionCfg *bindConnectionConfig
 }
 
@@ -28,7 +52,21 @@ type MiddlewareOption interface {
 	applyMiddlewareOption(cfg *middlewareConfig)
 }
 
+type interceptorConfig struct {
+	tp                trace.TracerProvider
+	reqIDGen          RequestIDGenerator
+	decideTenant      DecideRPCTenantFunc
+	registry          TenantRegistry
+	bindConnectionCfg *bindConnectionConfig
+}
+
+// InterceptorOption applies a configuration option value to gRPC interceptors.
+type InterceptorOption interface {
+	applyInterceptorOption(cfg *interceptorConfig)
+}
+
 type bindConnectionConfig struct {
+	logger              *slog.Logger
 	changeTenantTimeout time.Duration
 }
 
@@ -39,6 +77,9 @@ type BindConnectionOption interface {
 type doConfig struct {
 	reqIDGen           RequestIDGenerator
 	tenantDecisionRet  TenantDecisionResult
+	registry           TenantRegistry
+	logger             *slog.Logger
+	txOptions          *sql.TxOptions
 	bindConnectionOpts []BindConnectionOption
 }
 
@@ -46,6 +87,92 @@ type DoOption interface {
 	applyDoOption(c *doConfig)
 }
 
+type createTenantConfig struct {
+	templateFS   fs.FS
+	spec         TenantSpec
+	templateName string
+}
+
+type CreateTenantOption interface {
+	applyCreateTenantOption(cfg *createTenantConfig)
+}
+
+type relocateTenantConfig struct {
+	dropSource bool
+}
+
+type RelocateTenantOption interface {
+	applyRelocateTenantOption(cfg *relocateTenantConfig)
+}
+
+type migratorConfig struct {
+	table           string
+	doOpts          []DoOption
+	concurrency     int
+	continueOnError bool
+}
+
+type MigratorOption interface {
+	applyMigratorOption(cfg *migratorConfig)
+}
+
+type eachConfig struct {
+	doOpts        []DoOption
+	concurrency   int
+	tenantTimeout time.Duration
+}
+
+type EachOption interface {
+	applyEachOption(cfg *eachConfig)
+}
+
+type subdomainConfig struct {
+	mapper     func(subdomain string) (Tenant, error)
+	baseDomain string
+	excluded   []string
+	stripPort  bool
+}
+
+type SubdomainOption interface {
+	applySubdomainOption(cfg *subdomainConfig)
+}
+
+type hostConfig struct {
+	trustedProxies []netip.Prefix
+}
+
+type HostOption interface {
+	applyHostOption(cfg *hostConfig)
+}
+
+type hostCacheConfig struct {
+	maxEntries int
+}
+
+type HostCacheOption interface {
+	applyHostCacheOption(cfg *hostCacheConfig)
+}
+
+type jwtConfig struct {
+	claim      string
+	keys       []jwtKey
+	parserOpts []jwt.ParserOption
+}
+
+type JWTOption interface {
+	applyJWTOption(cfg *jwtConfig)
+}
+
+type errorHandlerConfig struct {
+	statusFuncs []ErrorStatusFunc
+	retryAfter  time.Duration
+	redact      bool
+}
+
+type ErrorHandlerOption interface {
+	applyErrorHandlerOption(cfg *errorHandlerConfig)
+}
+
 type optTracerProvider struct{ tp trace.TracerProvider }
 
 func (o *optTracerProvider) applyNewOption(cfg *newConfig) {
@@ -54,14 +181,164 @@ func (o *optTracerProvider) applyNewOption(cfg *newConfig) {
 
 func (o *optTracerProvider) applyMiddlewareOption(cfg *middlewareConfig) { cfg.tp = o.tp }
 
+func (o *optTracerProvider) applyInterceptorOption(cfg *interceptorConfig) { cfg.tp = o.tp }
+
 // WithTracerProvider creates an Option tells that use given TracerProvider.
 func WithTracerProvider(tp trace.TracerProvider) interface {
 	NewOption
 	MiddlewareOption
+	InterceptorOption
 } {
 	return &optTracerProvider{tp: tp}
 }
 
+type optMeterProvider struct{ mp metric.MeterProvider }
+
+func (o *optMeterProvider) applyNewOption(cfg *newConfig) { cfg.mp = o.mp }
+
+// WithMeterProvider tells the Nagaya to record the metrics by given MeterProvider.
+//
+// The global MeterProvider is used if not given.
+func WithMeterProvider(mp metric.MeterProvider) NewOption {
+	return &optMeterProvider{mp: mp}
+}
+
+type optTenantMetricsLimit struct{ limit int }
+
+func (o *optTenantMetricsLimit) applyNewOption(cfg *newConfig) { cfg.tenantMetricsLimit = o.limit }
+
+// WithTenantMetricsLimit sets the maximum number of tenants that distinguished in the metrics.
+//
+// The requests for the tenants over the limit are recorded as [OtherTenants].
+// The default is 100.
+func WithTenantMetricsLimit(limit int) NewOption {
+	return &optTenantMetricsLimit{limit: limit}
+}
+
+type optLogger struct{ logger *slog.Logger }
+
+func (o *optLogger) applyNewOption(cfg *newConfig) { cfg.logger = o.logger }
+
+func (o *optLogger) applyMiddlewareOption(cfg *middlewareConfig) { cfg.logger = o.logger }
+
+func (o *optLogger) applyDoOption(cfg *doConfig) { cfg.logger = o.logger }
+
+func (o *optLogger) applyBindConnectionOption(cfg *bindConnectionConfig) { cfg.logger = o.logger }
+
+// WithLogger tells that log the events such as binding and releasing connections by given logger.
+//
+// The logs of [Middleware], [Do] and [Nagaya.BindConnection] are written by the logger of the Nagaya if not given,
+// and nothing is logged if the Nagaya is not given one either.
+// The logger given to them is also used for the events of the bound connection until it is released.
+func WithLogger(logger *slog.Logger) interface {
+	NewOption
+	MiddlewareOption
+	DoOption
+	BindConnectionOption
+} {
+	return &optLogger{logger: logger}
+}
+
+type optHook struct{ register any }
+
+func (o *optHook) applyNewOption(cfg *newConfig) { cfg.hooks = append(cfg.hooks, o.register) }
+
+type optTxOptions struct{ opts *sql.TxOptions }
+
+func (o *optTxOptions) applyDoOption(cfg *doConfig) { cfg.txOptions = o.opts }
+
+func (o *optTxOptions) applyMiddlewareOption(cfg *middlewareConfig) { cfg.txOptions = o.opts }
+
+// WithTxOptions tells [DoTx], [YieldTx] and [Middleware] with [WithTransaction] to begin the transaction with given options.
+func WithTxOptions(opts *sql.TxOptions) interface {
+	DoOption
+	MiddlewareOption
+} {
+	return &optTxOptions{opts: opts}
+}
+
+type optTransaction struct{}
+
+func (optTransaction) applyMiddlewareOption(cfg *middlewareConfig) { cfg.tx = true }
+
+// WithTransaction tells the middleware to wrap each request in a transaction on the bound connection.
+//
+// The transaction is committed if the response status is below 400, and rolled back otherwise or if the handler panics.
+// The commit runs before the response header is sent, so if it fails, the response of the handler is discarded
+// and the error handler responds the failure instead.
+// The handler can get the transaction by [Nagaya.ObtainTx], and it may commit or roll back the transaction by itself.
+func WithTransaction() MiddlewareOption { return optTransaction{} }
+
+type optReplicas struct{ replicas any }
+
+func (o *optReplicas) applyNewOption(cfg *newConfig) { cfg.replicas = append(cfg.replicas, o.replicas) }
+
+type optReplicaPolicy struct{ policy ReplicaPolicy }
+
+func (o *optReplicaPolicy) applyNewOption(cfg *newConfig) { cfg.replicaPolicy = o.policy }
+
+// WithReplicaPolicy tells the Nagaya to choose the replica by given [ReplicaPolicy].
+//
+// [RoundRobin] is used if not given.
+func WithReplicaPolicy(policy ReplicaPolicy) NewOption {
+	return &optReplicaPolicy{policy: policy}
+}
+
+type optShards struct{ shards any }
+
+func (o *optShards) applyNewOption(cfg *newConfig) { cfg.shards = append(cfg.shards, o.shards) }
+
+type optShardResolver struct{ resolver ShardResolver }
+
+func (o *optShardResolver) applyNewOption(cfg *newConfig) { cfg.shardResolver = o.resolver }
+
+// WithShardResolver tells the Nagaya to decide the shard of the tenant by given [ShardResolver].
+func WithShardResolver(resolver ShardResolver) NewOption {
+	return &optShardResolver{resolver: resolver}
+}
+
+type optRelocationWait struct{ wait time.Duration }
+
+func (o *optRelocationWait) applyNewOption(cfg *newConfig) { cfg.relocationWait = o.wait }
+
+// WithRelocationWait tells the Nagaya how long [Nagaya.BindConnection] waits for the relocation of the tenant to finish.
+//
+// It fails with [ErrTenantRelocating] immediately if not given.
+func WithRelocationWait(wait time.Duration) NewOption {
+	return &optRelocationWait{wait: wait}
+}
+
+type optDropSource struct{}
+
+func (optDropSource) applyRelocateTenantOption(cfg *relocateTenantConfig) { cfg.dropSource = true }
+
+// WithDropSource tells the Nagaya to drop the tenant in the source shard after the relocation.
+//
+// It cannot be used with [ShardMap] that loses the relocated shards on restart.
+func WithDropSource() RelocateTenantOption { return optDropSource{} }
+
+type optTenantSwitcher struct{ switcher TenantSwitcher }
+
+func (o *optTenantSwitcher) applyNewOption(cfg *newConfig) { cfg.switcher = o.switcher }
+
+// WithTenantSwitcher tells the Nagaya to use given [TenantSwitcher] to change the tenant.
+//
+// [MySQLTenantSwitcher] is used if not given.
+func WithTenantSwitcher(switcher TenantSwitcher) NewOption {
+	return &optTenantSwitcher{switcher: switcher}
+}
+
+type optTenantRule struct{ rule *TenantRule }
+
+func (o *optTenantRule) applyNewOption(cfg *newConfig) { cfg.rule = o.rule }
+
+// WithTenantRule tells the Nagaya to reject tenants that do not satisfy given [TenantRule].
+//
+// The default rule described in [Tenant.Validate] is used if not given.
+func WithTenantRule(rule *TenantRule) NewOption {
+	return &optTenantRule{rule: rule}
+}
+
 type optTimeout struct{ dur time.Duration }
 
 func (o *optTimeout) applyMiddlewareOption(cfg *middlewareConfig) {
@@ -71,6 +348,13 @@ func (o *optTimeout) applyMiddlewareOption(cfg *middlewareConfig) {
 	o.applyBindConnectionOption(cfg.bindConnectionCfg)
 }
 
+func (o *optTimeout) applyInterceptorOption(cfg *interceptorConfig) {
+	if cfg.bindConnectionCfg == nil {
+		cfg.bindConnectionCfg = new(bindConnectionConfig)
+	}
+	o.applyBindConnectionOption(cfg.bindConnectionCfg)
+}
+
 func (o *optTimeout) applyBindConnectionOption(cfg *bindConnectionConfig) {
 	cfg.changeTenantTimeout = o.dur
 }
@@ -84,6 +368,7 @@ func WithTimeout(dur time.Duration) interface {
 	MiddlewareOption
 	BindConnectionOption
 	DoOption
+	InterceptorOption
 } {
 	return &optTimeout{dur: dur}
 }
@@ -96,6 +381,22 @@ func (o *optDecideTenantFn) applyMiddlewareOption(cfg *middlewareConfig) {
 	cfg.decideTenant = o.fn
 }
 
+type optRewriteRequest struct {
+	fn func(*http.Request) *http.Request
+}
+
+func (o *optRewriteRequest) applyMiddlewareOption(cfg *middlewareConfig) {
+	cfg.rewriteRequest = o.fn
+}
+
+type optMiddlewareOptions struct{ opts []MiddlewareOption }
+
+func (o *optMiddlewareOptions) applyMiddlewareOption(cfg *middlewareConfig) {
+	for _, opt := range o.opts {
+		opt.applyMiddlewareOption(cfg)
+	}
+}
+
 // WithDecideTenantFn tells the middleware to use given function to decide the tenant.
 func WithDecideTenantFn(fn DecideRequestTenantFunc) MiddlewareOption {
 	return &optDecideTenantFn{fn: fn}
@@ -103,15 +404,20 @@ func WithDecideTenantFn(fn DecideRequestTenantFunc) MiddlewareOption {
 
 // DecideTenantFromHeader tells the middleware to use given header value to decide the tenant.
 func DecideTenantFromHeader(headerName string) MiddlewareOption {
-	return &optDecideTenantFn{
-		fn: func(r *http.Request) TenantDecisionResult {
-			tenant := r.Header.Get(headerName)
-			if tenant == "" {
-				return &TenantDecisionResultError{Err: ErrNoTenantBound}
-			}
-			return &TenantDecisionResultChangeTenant{Tenant: Tenant(tenant)}
-		},
-	}
+	return &optDecideTenantFn{fn: HeaderTenant(headerName)}
+}
+
+type optDecideRPCTenantFn struct {
+	fn DecideRPCTenantFunc
+}
+
+func (o *optDecideRPCTenantFn) applyInterceptorOption(cfg *interceptorConfig) {
+	cfg.decideTenant = o.fn
+}
+
+// WithDecideRPCTenantFn tells the interceptor to use given function to decide the tenant.
+func WithDecideRPCTenantFn(fn DecideRPCTenantFunc) InterceptorOption {
+	return &optDecideRPCTenantFn{fn: fn}
 }
 
 type optRequestIDGenerator struct{ gen RequestIDGenerator }
@@ -120,10 +426,15 @@ func (o *optRequestIDGenerator) applyMiddlewareOption(cfg *middlewareConfig) { c
 
 func (o *optRequestIDGenerator) applyDoOption(c *doConfig) { c.reqIDGen = o.gen }
 
+func (o *optRequestIDGenerator) applyInterceptorOption(cfg *interceptorConfig) {
+	cfg.reqIDGen = o.gen
+}
+
 // WithRequestIDGenerator tells the middleware to use given [RequestIDGenerator].
 func WithRequestIDGenerator(gen RequestIDGenerator) interface {
 	MiddlewareOption
 	DoOption
+	InterceptorOption
 } {
 	return &optRequestIDGenerator{gen: gen}
 }
@@ -139,6 +450,27 @@ func WithErrorHandler(handler ErrorHandler) MiddlewareOption {
 	return &optErrorHandler{handler: handler}
 }
 
+type optTenantRegistry struct{ registry TenantRegistry }
+
+func (o *optTenantRegistry) applyMiddlewareOption(cfg *middlewareConfig) { cfg.registry = o.registry }
+
+func (o *optTenantRegistry) applyDoOption(c *doConfig) { c.registry = o.registry }
+
+func (o *optTenantRegistry) applyInterceptorOption(cfg *interceptorConfig) {
+	cfg.registry = o.registry
+}
+
+// WithTenantRegistry tells the middleware to reject tenants that are not registered in given [TenantRegistry].
+//
+// The unknown tenant is rejected with an [UnknownTenantError] before any connection is obtained.
+func WithTenantRegistry(registry TenantRegistry) interface {
+	MiddlewareOption
+	DoOption
+	InterceptorOption
+} {
+	return &optTenantRegistry{registry: registry}
+}
+
 func WithTenantDecisionResult(r TenantDecisionResult) DoOption { return &optTenantDecisionResult{r} }
 
 type optTenantDecisionResult struct{ TenantDecisionResult }
@@ -146,3 +478,236 @@ type optTenantDecisionResult struct{ TenantDecisionResult }
 func (o *optTenantDecisionResult) applyDoOption(c *doConfig) {
 	c.tenantDecisionRet = o.TenantDecisionResult
 }
+
+type optCharset struct{ charset string }
+
+func (o *optCharset) applyCreateTenantOption(cfg *createTenantConfig) { cfg.spec.Charset = o.charset }
+
+// WithCharset tells the Nagaya to create the tenant with given default character set.
+func WithCharset(charset string) CreateTenantOption { return &optCharset{charset: charset} }
+
+type optCollation struct{ collation string }
+
+func (o *optCollation) applyCreateTenantOption(cfg *createTenantConfig) {
+	cfg.spec.Collation = o.collation
+}
+
+// WithCollation tells the Nagaya to create the tenant with given default collation.
+func WithCollation(collation string) CreateTenantOption { return &optCollation{collation: collation} }
+
+type optSchemaTemplate struct {
+	fsys fs.FS
+	name string
+}
+
+func (o *optSchemaTemplate) applyCreateTenantOption(cfg *createTenantConfig) {
+	cfg.templateFS = o.fsys
+	cfg.templateName = o.name
+}
+
+// WithSchemaTemplate tells the Nagaya to apply the SQL script in the file to the created tenant.
+//
+// The statements in the script are separated by semicolons and run against the created tenant.
+// The semicolons in the quotes, the comments and the dollar-quoted strings of PostgreSQL do not separate the statements,
+// and the backslashes escape the quotes unless the [PostgreSQLTenantSwitcher] is used.
+// The client commands such as DELIMITER of the mysql command are not supported.
+func WithSchemaTemplate(fsys fs.FS, name string) CreateTenantOption {
+	return &optSchemaTemplate{fsys: fsys, name: name}
+}
+
+type optConcurrency struct{ n int }
+
+func (o *optConcurrency) applyMigratorOption(cfg *migratorConfig) { cfg.concurrency = o.n }
+
+func (o *optConcurrency) applyEachOption(cfg *eachConfig) { cfg.concurrency = o.n }
+
+// WithConcurrency sets how many tenants are processed at the same time.
+//
+// The tenants are processed one by one by default.
+func WithConcurrency(n int) interface {
+	MigratorOption
+	EachOption
+} {
+	return &optConcurrency{n: n}
+}
+
+type optTenantTimeout struct{ dur time.Duration }
+
+func (o *optTenantTimeout) applyEachOption(cfg *eachConfig) { cfg.tenantTimeout = o.dur }
+
+// WithTenantTimeout sets how long the handler can take for each tenant.
+func WithTenantTimeout(dur time.Duration) EachOption { return &optTenantTimeout{dur: dur} }
+
+type optContinueOnError struct{}
+
+func (optContinueOnError) applyMigratorOption(cfg *migratorConfig) { cfg.continueOnError = true }
+
+// WithContinueOnError tells the migrator to migrate the rest of tenants even if some tenant fails.
+func WithContinueOnError() MigratorOption { return optContinueOnError{} }
+
+type optMigrationTable struct{ table string }
+
+func (o *optMigrationTable) applyMigratorOption(cfg *migratorConfig) { cfg.table = o.table }
+
+// WithMigrationTable sets the name of the bookkeeping table that records the applied versions.
+//
+// The name is quoted as one identifier for the dialect, so it cannot be qualified by the database or the schema.
+// The default is nagaya_schema_migrations.
+func WithMigrationTable(table string) MigratorOption { return &optMigrationTable{table: table} }
+
+type optDoOptions struct{ opts []DoOption }
+
+func (o *optDoOptions) applyMigratorOption(cfg *migratorConfig) {
+	cfg.doOpts = append(cfg.doOpts, o.opts...)
+}
+
+func (o *optDoOptions) applyEachOption(cfg *eachConfig) {
+	cfg.doOpts = append(cfg.doOpts, o.opts...)
+}
+
+// WithDoOptions passes the options to [Do] that binds the connection for each tenant.
+func WithDoOptions(opts ...DoOption) interface {
+	MigratorOption
+	EachOption
+} {
+	return &optDoOptions{opts: opts}
+}
+
+type optBaseDomain struct{ domain string }
+
+func (o *optBaseDomain) applySubdomainOption(cfg *subdomainConfig) {
+	cfg.baseDomain = strings.ToLower(strings.Trim(o.domain, "."))
+}
+
+// WithBaseDomain tells the decision function that the tenants are subdomains of given domain.
+//
+// Without it, the leftmost label of the host that has three or more labels is used.
+// The requests to the hosts outside of the base domain are decided as [TenantDecisionResultError].
+func WithBaseDomain(domain string) SubdomainOption { return &optBaseDomain{domain: domain} }
+
+type optExcludedSubdomains struct{ subdomains []string }
+
+func (o *optExcludedSubdomains) applySubdomainOption(cfg *subdomainConfig) {
+	for _, subdomain := range o.subdomains {
+		cfg.excluded = append(cfg.excluded, strings.ToLower(subdomain))
+	}
+}
+
+// WithExcludedSubdomains tells the decision function not to treat given subdomains such as www as tenants.
+func WithExcludedSubdomains(subdomains ...string) SubdomainOption {
+	return &optExcludedSubdomains{subdomains: subdomains}
+}
+
+type optPortStripping struct{ strip bool }
+
+func (o *optPortStripping) applySubdomainOption(cfg *subdomainConfig) { cfg.stripPort = o.strip }
+
+// WithPortStripping sets whether the port of the host is stripped before the subdomain is taken.
+//
+// It is enabled by default.
+func WithPortStripping(strip bool) SubdomainOption { return &optPortStripping{strip: strip} }
+
+type optSubdomainMapper struct {
+	fn func(subdomain string) (Tenant, error)
+}
+
+func (o *optSubdomainMapper) applySubdomainOption(cfg *subdomainConfig) { cfg.mapper = o.fn }
+
+// WithSubdomainMapper tells the decision function to map the subdomain to the tenant by given function.
+//
+// The error returned by the function is decided as [TenantDecisionResultError].
+func WithSubdomainMapper(fn func(subdomain string) (Tenant, error)) SubdomainOption {
+	return &optSubdomainMapper{fn: fn}
+}
+
+type optTrustedProxies struct{ prefixes []netip.Prefix }
+
+func (o *optTrustedProxies) applyHostOption(cfg *hostConfig) {
+	cfg.trustedProxies = append(cfg.trustedProxies, o.prefixes...)
+}
+
+// WithTrustedProxies tells the decision function to respect the X-Forwarded-Host header sent from given networks.
+func WithTrustedProxies(prefixes ...netip.Prefix) HostOption {
+	return &optTrustedProxies{prefixes: prefixes}
+}
+
+type optHostCacheSize struct{ n int }
+
+func (o *optHostCacheSize) applyHostCacheOption(cfg *hostCacheConfig) { cfg.maxEntries = o.n }
+
+// WithHostCacheSize sets how many hosts [CachedHostTenantLookup] keeps at most.
+//
+// The default is 10000.
+func WithHostCacheSize(n int) HostCacheOption { return &optHostCacheSize{n: n} }
+
+type optJWTKeys struct{ keys []jwtKey }
+
+func (o *optJWTKeys) applyJWTOption(cfg *jwtConfig) { cfg.keys = append(cfg.keys, o.keys...) }
+
+// WithHMACSecret tells the decision function to verify the tokens signed by HS256, HS384 or HS512 with given secret.
+func WithHMACSecret(secret []byte) JWTOption {
+	return &optJWTKeys{keys: []jwtKey{{key: secret}}}
+}
+
+// WithVerificationKey tells the decision function to verify the tokens by given RSA or ECDSA public key.
+//
+// If the kid is not empty, the key is used only for the tokens that have the same key ID.
+func WithVerificationKey(kid string, key crypto.PublicKey) JWTOption {
+	return &optJWTKeys{keys: []jwtKey{{id: kid, key: key}}}
+}
+
+// WithJWKS tells the decision function to verify the tokens by the keys in given [JWKS].
+func WithJWKS(set *JWKS) JWTOption {
+	return &optJWTKeys{keys: set.keys}
+}
+
+type optTenantClaim struct{ claim string }
+
+func (o *optTenantClaim) applyJWTOption(cfg *jwtConfig) { cfg.claim = o.claim }
+
+// WithTenantClaim tells the decision function to read the tenant from given claim.
+func WithTenantClaim(claim string) JWTOption { return &optTenantClaim{claim: claim} }
+
+type optJWTParserOptions struct{ opts []jwt.ParserOption }
+
+func (o *optJWTParserOptions) applyJWTOption(cfg *jwtConfig) {
+	cfg.parserOpts = append(cfg.parserOpts, o.opts...)
+}
+
+// WithJWTParserOptions passes the options to the parser such as [jwt.WithIssuer] and [jwt.WithAudience].
+func WithJWTParserOptions(opts ...jwt.ParserOption) JWTOption {
+	return &optJWTParserOptions{opts: opts}
+}
+
+type optErrorStatus struct{ fn ErrorStatusFunc }
+
+func (o *optErrorStatus) applyErrorHandlerOption(cfg *errorHandlerConfig) {
+	cfg.statusFuncs = append(cfg.statusFuncs, o.fn)
+}
+
+// WithErrorStatus tells the error handler to decide the status code by given function prior to the default mappings.
+//
+// It is useful for the user-defined error types, for example:
+//
+//	nagaya.WithErrorStatus(nagaya.ErrorStatusAs[*MyError](http.StatusForbidden))
+func WithErrorStatus(fn ErrorStatusFunc) ErrorHandlerOption { return &optErrorStatus{fn: fn} }
+
+type optRetryAfter struct{ dur time.Duration }
+
+func (o *optRetryAfter) applyErrorHandlerOption(cfg *errorHandlerConfig) { cfg.retryAfter = o.dur }
+
+// WithRetryAfter sets the Retry-After header value of 503 responses.
+//
+// The default is 1 second.
+func WithRetryAfter(dur time.Duration) ErrorHandlerOption { return &optRetryAfter{dur: dur} }
+
+type optRedactErrors struct{}
+
+func (optRedactErrors) applyErrorHandlerOption(cfg *errorHandlerConfig) { cfg.redact = true }
+
+// WithRedactedErrors tells the error handler not to expose the messages of server errors (5xx) to the clients.
+//
+// The messages of such errors may contain the underlying driver errors like "Error 1044 (42000): Access denied for user",
+// so it is recommended to enable it in production.
+// The messages of client errors (4xx) are still exposed because they tell how to fix the request.
+func WithRedactedErrors() ErrorHandlerOption { return optRedactErrors{} }
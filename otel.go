@@ -10,6 +10,11 @@ import (
 var (
 	KeyTenant    = attribute.Key("nagaya.tenant")
 	KeyRequestID = attribute.Key("nagaya.request_id")
+	KeyNewTenant = attribute.Key("nagaya.new_tenant")
+	KeyPhase     = attribute.Key("nagaya.phase")
+	KeyErrorKind = attribute.Key("nagaya.error_kind")
+	KeyReplica   = attribute.Key("nagaya.replica")
+	KeyShard     = attribute.Key("nagaya.shard")
 )
 
 func getTracer(tracerProvider trace.TracerProvider) trace.Tracer {
@@ -0,0 +1,149 @@
+package nagaya
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PathPrefixTenant returns a [DecideRequestTenantFunc] that uses the first path segment after the prefix as the tenant.
+//
+// For example, with the prefix /t/ the request to /t/acme/users is bound for the tenant acme.
+// The requests outside of the prefix are decided as [TenantDecisionResultError] with [ErrNoTenantBound].
+// It does not rewrite the request path; use [WithStripPathPrefix] together or [DecideTenantFromPathPrefix] to do so.
+func PathPrefixTenant(prefix string) DecideRequestTenantFunc {
+	prefix = normalizePathPrefix(prefix)
+	return func(r *http.Request) TenantDecisionResult {
+		escaped, _, ok := cutTenantSegment(r.URL.EscapedPath(), prefix)
+		if !ok {
+			return &TenantDecisionResultError{Err: ErrNoTenantBound}
+		}
+		tenant, err := url.PathUnescape(escaped)
+		if err != nil {
+			return &TenantDecisionResultError{Err: ErrNoTenantBound}
+		}
+		return &TenantDecisionResultChangeTenant{Tenant: Tenant(tenant)}
+	}
+}
+
+// WithStripPathPrefix tells the middleware to strip the prefix and the following tenant segment from the request path,
+// so the next handler receives the request to /users instead of /t/acme/users and the downstream routers do not need to know about the tenants.
+//
+// The requests outside of the prefix are passed as is.
+// It is independent of how the tenant is decided, so it can be combined with [WithDecideTenantFn] in any order.
+func WithStripPathPrefix(prefix string) MiddlewareOption {
+	prefix = normalizePathPrefix(prefix)
+	return &optRewriteRequest{fn: func(r *http.Request) *http.Request {
+		_, rawPath, ok := cutTenantSegment(r.URL.EscapedPath(), prefix)
+		if !ok {
+			return r
+		}
+		return withRawPath(r, rawPath)
+	}}
+}
+
+// DecideTenantFromPathPrefix is a shorthand of [WithDecideTenantFn] with [PathPrefixTenant] and [WithStripPathPrefix].
+//
+// The later [WithDecideTenantFn] replaces only how the tenant is decided, and the path is still stripped.
+func DecideTenantFromPathPrefix(prefix string) MiddlewareOption {
+	return &optMiddlewareOptions{opts: []MiddlewareOption{WithDecideTenantFn(PathPrefixTenant(prefix)), WithStripPathPrefix(prefix)}}
+}
+
+// PathValueTenant returns a [DecideRequestTenantFunc] that uses the wildcard of [http.ServeMux] pattern as the tenant.
+//
+// The middleware must wrap the handler registered with the pattern that has the wildcard such as /t/{tenant}/.
+// It does not rewrite the request path; use [WithStripPathValue] together or [DecideTenantFromPathValue] to do so.
+func PathValueTenant(name string) DecideRequestTenantFunc {
+	return func(r *http.Request) TenantDecisionResult {
+		tenant := r.PathValue(name)
+		if tenant == "" {
+			return &TenantDecisionResultError{Err: ErrNoTenantBound}
+		}
+		return &TenantDecisionResultChangeTenant{Tenant: Tenant(tenant)}
+	}
+}
+
+// WithStripPathValue tells the middleware to strip the path segments up to the wildcard of [http.ServeMux] pattern from the request path.
+//
+// The requests that the pattern has no such wildcard are passed as is.
+// It is independent of how the tenant is decided, so it can be combined with [WithDecideTenantFn] in any order.
+func WithStripPathValue(name string) MiddlewareOption {
+	return &optRewriteRequest{fn: func(r *http.Request) *http.Request {
+		n := wildcardSegmentIndex(r.Pattern, name)
+		if n < 0 {
+			return r
+		}
+		return withRawPath(r, dropSegments(r.URL.EscapedPath(), n+1))
+	}}
+}
+
+// DecideTenantFromPathValue is a shorthand of [WithDecideTenantFn] with [PathValueTenant] and [WithStripPathValue].
+//
+// The later [WithDecideTenantFn] replaces only how the tenant is decided, and the path is still stripped.
+func DecideTenantFromPathValue(name string) MiddlewareOption {
+	return &optMiddlewareOptions{opts: []MiddlewareOption{WithDecideTenantFn(PathValueTenant(name)), WithStripPathValue(name)}}
+}
+
+func normalizePathPrefix(prefix string) string {
+	prefix = "/" + strings.Trim(prefix, "/") + "/"
+	if prefix == "//" {
+		return "/"
+	}
+	return prefix
+}
+
+// cutTenantSegment returns the first segment after the prefix and the rest of the path.
+func cutTenantSegment(path, prefix string) (tenant, rest string, ok bool) {
+	after, found := strings.CutPrefix(path, prefix)
+	if !found {
+		return "", "", false
+	}
+	tenant, rest, _ = strings.Cut(after, "/")
+	if tenant == "" {
+		return "", "", false
+	}
+	return tenant, "/" + rest, true
+}
+
+// wildcardSegmentIndex returns the index of the path segment that has the wildcard in the ServeMux pattern.
+func wildcardSegmentIndex(pattern, name string) int {
+	if _, p, found := strings.Cut(pattern, " "); found {
+		pattern = p
+	}
+	i := strings.Index(pattern, "/")
+	if i < 0 {
+		return -1
+	}
+	for n, segment := range strings.Split(strings.Trim(pattern[i:], "/"), "/") {
+		if segment == "{"+name+"}" {
+			return n
+		}
+	}
+	return -1
+}
+
+func dropSegments(path string, n int) string {
+	rest := strings.TrimPrefix(path, "/")
+	for range n {
+		_, rest, _ = strings.Cut(rest, "/")
+	}
+	return "/" + rest
+}
+
+// withRawPath returns a shallow copy of the request that has the escaped path.
+func withRawPath(r *http.Request, rawPath string) *http.Request {
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return r
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	if rawPath != r2.URL.EscapedPath() {
+		r2.URL.RawPath = rawPath
+	}
+	return r2
+}
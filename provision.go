@@ -0,0 +1,454 @@
+package nagaya
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TenantSpec is a specification of the tenant to be created.
+type TenantSpec struct {
+	// Charset is a default character set of the tenant.
+	Charset string
+	// Collation is a default collation of the tenant.
+	Collation string
+}
+
+// TenantProvisioner runs the dialect-specific DDL to manage the tenants.
+//
+// [MySQLTenantSwitcher] and [PostgreSQLTenantSwitcher] implement it.
+type TenantProvisioner interface {
+	// CreateTenant creates the tenant.
+	//
+	// It returns a [TenantAlreadyExistsError] if the tenant already exists.
+	CreateTenant(ctx context.Context, conn Connish, tenant Tenant, spec *TenantSpec) error
+	// DropTenant drops the tenant.
+	//
+	// It returns an [UnknownTenantError] if the tenant does not exist.
+	DropTenant(ctx context.Context, conn Connish, tenant Tenant) error
+	// RenameTenant renames the tenant.
+	//
+	// It returns an [UnknownTenantError] if the tenant does not exist and a [TenantAlreadyExistsError] if the new one already exists.
+	RenameTenant(ctx context.Context, conn Connish, from, to Tenant) error
+}
+
+// CreateTenant creates the tenant and applies the schema template if given.
+//
+// If the schema template fails, the created tenant is dropped and an [ApplySchemaTemplateError] is returned.
+// The [TenantSwitcher] must implement [TenantProvisioner].
+// If the shards are configured by [WithShards], the tenant is created in the shard that it belongs to.
+func (n *Nagaya[DB, Conn]) CreateTenant(ctx context.Context, tenant Tenant, opts ...CreateTenantOption) (err error) {
+	ctx, span := n.tracer.Start(ctx, "Nagaya.CreateTenant", trace.WithAttributes(attrTenant(tenant)))
+	defer func() { finishSpan(span, err) }()
+
+	var cfg createTenantConfig
+	for _, o := range opts {
+		o.applyCreateTenantOption(&cfg)
+	}
+	provisioner, err := n.provisioner()
+	if err != nil {
+		return err
+	}
+	if err := n.rule.Validate(tenant); err != nil {
+		return err
+	}
+	var stmts []string
+	if cfg.templateFS != nil {
+		content, err := fs.ReadFile(cfg.templateFS, cfg.templateName)
+		if err != nil {
+			return fmt.Errorf("failed to read schema template: %w", err)
+		}
+		stmts = splitStatements(string(content), n.standardStrings())
+	}
+	db, _, err := n.dbFor(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	conn, err := n.getConn(ctx, db)
+	if err != nil {
+		return &ObtainConnectionError{err: err}
+	}
+	defer func() { _ = conn.Close() }()
+	if err := provisioner.CreateTenant(ctx, conn, tenant, &cfg.spec); err != nil {
+		return err
+	}
+	if len(stmts) == 0 {
+		return nil
+	}
+	if err := switchTenant(ctx, n.switcher, conn, tenant, defaultChangeTenantTimeout); err != nil {
+		discardConnection(conn)
+		return err
+	}
+	defer func() { _ = n.resetConnection(ctx, conn, n.logger) }()
+	for _, stmt := range stmts {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			applyErr := &ApplySchemaTemplateError{err: err, tenant: tenant}
+			// the half-provisioned tenant is dropped so that the creation can be retried.
+			if dropErr := provisioner.DropTenant(context.WithoutCancel(ctx), conn, tenant); dropErr != nil {
+				return errors.Join(applyErr, fmt.Errorf("failed to drop the tenant: %w", dropErr))
+			}
+			return applyErr
+		}
+	}
+	return nil
+}
+
+// DropTenant drops the tenant.
+//
+// The [TenantSwitcher] must implement [TenantProvisioner].
+func (n *Nagaya[DB, Conn]) DropTenant(ctx context.Context, tenant Tenant) (err error) {
+	ctx, span := n.tracer.Start(ctx, "Nagaya.DropTenant", trace.WithAttributes(attrTenant(tenant)))
+	defer func() { finishSpan(span, err) }()
+
+	provisioner, err := n.provisioner()
+	if err != nil {
+		return err
+	}
+	if err := n.rule.Validate(tenant); err != nil {
+		return err
+	}
+	db, _, err := n.dbFor(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	conn, err := n.getConn(ctx, db)
+	if err != nil {
+		return &ObtainConnectionError{err: err}
+	}
+	defer func() { _ = conn.Close() }()
+	return provisioner.DropTenant(ctx, conn, tenant)
+}
+
+// RenameTenant renames the tenant.
+//
+// The [TenantSwitcher] must implement [TenantProvisioner].
+// The tenant is renamed in the shard of the old name, so the [ShardResolver] must resolve the new name to the same shard.
+func (n *Nagaya[DB, Conn]) RenameTenant(ctx context.Context, from, to Tenant) (err error) {
+	ctx, span := n.tracer.Start(ctx, "Nagaya.RenameTenant", trace.WithAttributes(attrTenant(from), KeyNewTenant.String(string(to))))
+	defer func() { finishSpan(span, err) }()
+
+	provisioner, err := n.provisioner()
+	if err != nil {
+		return err
+	}
+	if err := n.rule.Validate(from); err != nil {
+		return err
+	}
+	if err := n.rule.Validate(to); err != nil {
+		return err
+	}
+	db, _, err := n.dbFor(ctx, from)
+	if err != nil {
+		return err
+	}
+	conn, err := n.getConn(ctx, db)
+	if err != nil {
+		return &ObtainConnectionError{err: err}
+	}
+	defer func() { _ = conn.Close() }()
+	return provisioner.RenameTenant(ctx, conn, from, to)
+}
+
+func (n *Nagaya[DB, Conn]) provisioner() (TenantProvisioner, error) {
+	provisioner, ok := n.switcher.(TenantProvisioner)
+	if !ok {
+		return nil, ErrProvisioningUnsupported
+	}
+	return provisioner, nil
+}
+
+// standardStrings reports whether the SQL scripts are parsed in the manner of PostgreSQL, which treats the backslashes in the strings as is.
+func (n *Nagaya[DB, Conn]) standardStrings() bool {
+	_, ok := n.switcher.(*PostgreSQLTenantSwitcher)
+	return ok
+}
+
+var _ TenantProvisioner = (*MySQLTenantSwitcher)(nil)
+
+// mysqlErrDBCreateExists is the error number of MySQL that tells the database to be created already exists.
+const mysqlErrDBCreateExists = 1007
+
+// CreateTenant creates the database of the tenant.
+//
+// The existence is told by the error of the DDL rather than checked in advance,
+// so that only one of the concurrent creations of the same tenant succeeds.
+func (s *MySQLTenantSwitcher) CreateTenant(ctx context.Context, conn Connish, tenant Tenant, spec *TenantSpec) error {
+	ddl, err := mysqlCreateDatabaseDDL(tenant, spec)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, ddl)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDBCreateExists {
+		return &TenantAlreadyExistsError{tenant: tenant}
+	}
+	return err
+}
+
+func (s *MySQLTenantSwitcher) DropTenant(ctx context.Context, conn Connish, tenant Tenant) error {
+	exists, err := tenantExists(ctx, conn, "?", tenant)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &UnknownTenantError{tenant: tenant}
+	}
+	_, err = conn.ExecContext(ctx, "drop database "+quoteMySQLIdentifier(string(tenant)))
+	return err
+}
+
+// RenameTenant moves all tables of the tenant to the new database because MySQL cannot rename the database.
+//
+// The views, triggers and routines are not moved.
+func (s *MySQLTenantSwitcher) RenameTenant(ctx context.Context, conn Connish, from, to Tenant) error {
+	exists, err := tenantExists(ctx, conn, "?", from)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &UnknownTenantError{tenant: from}
+	}
+	exists, err = tenantExists(ctx, conn, "?", to)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return &TenantAlreadyExistsError{tenant: to}
+	}
+	var spec TenantSpec
+	if err := conn.QueryRowContext(ctx, "select default_character_set_name, default_collation_name from information_schema.schemata where schema_name = ?", string(from)).Scan(&spec.Charset, &spec.Collation); err != nil {
+		return err
+	}
+	tables, err := mysqlListTables(ctx, conn, from)
+	if err != nil {
+		return err
+	}
+	ddl, err := mysqlCreateDatabaseDDL(to, &spec)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, ddl); err != nil {
+		return err
+	}
+	if len(tables) > 0 {
+		renames := make([]string, len(tables))
+		for i, table := range tables {
+			renames[i] = fmt.Sprintf("%s.%s to %s.%s",
+				quoteMySQLIdentifier(string(from)), quoteMySQLIdentifier(table),
+				quoteMySQLIdentifier(string(to)), quoteMySQLIdentifier(table))
+		}
+		if _, err := conn.ExecContext(ctx, "rename table "+strings.Join(renames, ", ")); err != nil {
+			return err
+		}
+	}
+	_, err = conn.ExecContext(ctx, "drop database "+quoteMySQLIdentifier(string(from)))
+	return err
+}
+
+func mysqlCreateDatabaseDDL(tenant Tenant, spec *TenantSpec) (string, error) {
+	ddl := "create database " + quoteMySQLIdentifier(string(tenant))
+	if spec.Charset != "" {
+		if err := validateWord(spec.Charset); err != nil {
+			return "", err
+		}
+		ddl += " character set " + spec.Charset
+	}
+	if spec.Collation != "" {
+		if err := validateWord(spec.Collation); err != nil {
+			return "", err
+		}
+		ddl += " collate " + spec.Collation
+	}
+	return ddl, nil
+}
+
+func mysqlListTables(ctx context.Context, conn Queryer, tenant Tenant) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "select table_name from information_schema.tables where table_schema = ? and table_type = 'BASE TABLE' order by table_name", string(tenant))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+var _ TenantProvisioner = (*PostgreSQLTenantSwitcher)(nil)
+
+// pgErrDuplicateSchema is the SQLSTATE of PostgreSQL that tells the schema to be created already exists.
+const pgErrDuplicateSchema = "42P06"
+
+// CreateTenant creates the schema of the tenant.
+//
+// PostgreSQL schemas have no character set nor collation, so the spec is ignored.
+// The existence is told by the error of the DDL like [MySQLTenantSwitcher.CreateTenant].
+func (s *PostgreSQLTenantSwitcher) CreateTenant(ctx context.Context, conn Connish, tenant Tenant, _ *TenantSpec) error {
+	_, err := conn.ExecContext(ctx, "create schema "+quotePostgreSQLIdentifier(string(tenant)))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgErrDuplicateSchema {
+		return &TenantAlreadyExistsError{tenant: tenant}
+	}
+	return err
+}
+
+// DropTenant drops the schema of the tenant and all objects in it.
+func (s *PostgreSQLTenantSwitcher) DropTenant(ctx context.Context, conn Connish, tenant Tenant) error {
+	exists, err := tenantExists(ctx, conn, "$1", tenant)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &UnknownTenantError{tenant: tenant}
+	}
+	_, err = conn.ExecContext(ctx, "drop schema "+quotePostgreSQLIdentifier(string(tenant))+" cascade")
+	return err
+}
+
+func (s *PostgreSQLTenantSwitcher) RenameTenant(ctx context.Context, conn Connish, from, to Tenant) error {
+	exists, err := tenantExists(ctx, conn, "$1", from)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &UnknownTenantError{tenant: from}
+	}
+	exists, err = tenantExists(ctx, conn, "$1", to)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return &TenantAlreadyExistsError{tenant: to}
+	}
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("alter schema %s rename to %s", quotePostgreSQLIdentifier(string(from)), quotePostgreSQLIdentifier(string(to))))
+	return err
+}
+
+// tenantExists reports whether the schema of the tenant exists by the standard information_schema.
+func tenantExists(ctx context.Context, conn Queryer, placeholder string, tenant Tenant) (bool, error) {
+	var count int
+	if err := conn.QueryRowContext(ctx, "select count(*) from information_schema.schemata where schema_name = "+placeholder, string(tenant)).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// validateWord validates the value that is embedded in DDL as a keyword such as a character set.
+func validateWord(word string) error {
+	for _, c := range word {
+		if !strings.ContainsRune(DefaultTenantCharset, c) {
+			return &InvalidSpecError{value: word}
+		}
+	}
+	return nil
+}
+
+// splitStatements splits the SQL script into statements by semicolons outside of quotes and comments.
+//
+// It understands the quotes (', " and `), the line comments (-- and # of MySQL) and the block comments (/* */).
+// The backslashes escape the next character in the quotes unless standardStrings is true,
+// in which case the backslashes are literal except in the escape strings such as E'\n' and the dollar-quoted strings ($$ or $tag$) of PostgreSQL are understood.
+// The line comments are dropped and the block comments are kept in the statements.
+func splitStatements(script string, standardStrings bool) []string {
+	var (
+		stmts   []string
+		current strings.Builder
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		current.Reset()
+	}
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-', c == '#' && !standardStrings:
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			if i < len(runes) {
+				current.WriteRune('\n')
+			}
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := closingIndex(runes, i+2, []rune("*/"))
+			current.WriteString(string(runes[i:end]))
+			i = end - 1
+		case c == '\'' || c == '"' || c == '`':
+			escapes := c != '`' && (!standardStrings || c == '\'' && isEscapeStringPrefix(runes, i))
+			j := i + 1
+			for ; j < len(runes) && runes[j] != c; j++ {
+				if escapes && runes[j] == '\\' {
+					j++
+				}
+			}
+			j = min(j, len(runes)-1)
+			current.WriteString(string(runes[i : j+1]))
+			i = j
+		case c == '$' && standardStrings && (i == 0 || !isIdentifierRune(runes[i-1])):
+			tag, ok := dollarQuoteTag(runes[i:])
+			if !ok {
+				current.WriteRune(c)
+				break
+			}
+			end := closingIndex(runes, i+len(tag), tag)
+			current.WriteString(string(runes[i:end]))
+			i = end - 1
+		case c == ';':
+			flush()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// closingIndex returns the index next to the closing delimiter found from the start, or the end of the runes if not found.
+func closingIndex(runes []rune, start int, delim []rune) int {
+	for j := start; j+len(delim) <= len(runes); j++ {
+		if slices.Equal(runes[j:j+len(delim)], delim) {
+			return j + len(delim)
+		}
+	}
+	return len(runes)
+}
+
+// isEscapeStringPrefix reports whether the quote at i starts the escape string constant of PostgreSQL such as E'\n'.
+func isEscapeStringPrefix(runes []rune, i int) bool {
+	return i > 0 && (runes[i-1] == 'E' || runes[i-1] == 'e') && (i == 1 || !isIdentifierRune(runes[i-2]))
+}
+
+// dollarQuoteTag returns the opening delimiter of the dollar-quoted string such as $$ or $body$.
+func dollarQuoteTag(runes []rune) ([]rune, bool) {
+	for j := 1; j < len(runes); j++ {
+		switch c := runes[j]; {
+		case c == '$':
+			return runes[:j+1], true
+		case c >= '0' && c <= '9' && j == 1, !isIdentifierRune(c):
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+func isIdentifierRune(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
@@ -0,0 +1,204 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+func TestNagaya_CreateTenant_mysql(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newMySQLNagayaForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testProvisioning(t, ngy, "tenant_schema.sql", nagaya.WithCharset("utf8mb4"), nagaya.WithCollation("utf8mb4_bin"))
+}
+
+func TestNagaya_CreateTenant_postgres(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newPostgreSQLNagayaForTesting(nagaya.WithTenantSwitcher(&nagaya.PostgreSQLTenantSwitcher{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testProvisioning(t, ngy, "tenant_schema_postgres.sql")
+}
+
+func TestNagaya_CreateTenant_unsupported(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newMySQLNagayaForTesting(nagaya.WithTenantSwitcher(new(recordingSwitcher)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ngy.CreateTenant(t.Context(), "tenant_unsupported"); !errors.Is(err, nagaya.ErrProvisioningUnsupported) {
+		t.Errorf("expected ErrProvisioningUnsupported but got: %v", err)
+	}
+}
+
+func testProvisioning(t *testing.T, ngy *nagaya.Nagaya[*sql.DB, *sql.Conn], template string, opts ...nagaya.CreateTenantOption) {
+	t.Helper()
+
+	ctx := t.Context()
+	tenant := nagaya.Tenant(fmt.Sprintf("tenant_prov_%d", time.Now().UnixNano()))
+	renamed := tenant + "_renamed"
+	opts = append(opts, nagaya.WithSchemaTemplate(os.DirFS("testdata"), template))
+	if err := ngy.CreateTenant(ctx, tenant, opts...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = ngy.DropTenant(context.WithoutCancel(ctx), tenant)
+		_ = ngy.DropTenant(context.WithoutCancel(ctx), renamed)
+	})
+	if err := insertUser(ctx, ngy, tenant); err != nil {
+		t.Errorf("failed to insert a user into the created tenant: %s", err)
+	}
+
+	var existsErr *nagaya.TenantAlreadyExistsError
+	if err := ngy.CreateTenant(ctx, tenant); !errors.As(err, &existsErr) {
+		t.Errorf("expected TenantAlreadyExistsError but got: %v", err)
+	}
+
+	if err := ngy.RenameTenant(ctx, tenant, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := insertUser(ctx, ngy, renamed); err != nil {
+		t.Errorf("failed to insert a user into the renamed tenant: %s", err)
+	}
+	var unknownErr *nagaya.UnknownTenantError
+	if err := ngy.RenameTenant(ctx, tenant, renamed); !errors.As(err, &unknownErr) {
+		t.Errorf("expected UnknownTenantError but got: %v", err)
+	}
+
+	if err := ngy.DropTenant(ctx, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := ngy.DropTenant(ctx, renamed); !errors.As(err, &unknownErr) {
+		t.Errorf("expected UnknownTenantError but got: %v", err)
+	}
+}
+
+func insertUser(ctx context.Context, ngy *nagaya.Nagaya[*sql.DB, *sql.Conn], tenant nagaya.Tenant) error {
+	handler := func(ctx context.Context) error {
+		conn, err := ngy.ObtainConnection(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = conn.ExecContext(ctx, "insert into users (id) values (default)")
+		return err
+	}
+	return nagaya.Do(ctx, ngy, handler, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: tenant}))
+}
+
+func TestNagaya_CreateTenant_templateFailure(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		switcher  nagaya.TenantSwitcher
+		template  string
+		wantExecs []string
+	}{
+		{
+			name:     "MySQL",
+			switcher: &nagaya.MySQLTenantSwitcher{},
+			template: "create table a (s text default 'it\\'s; fine');\n" +
+				"/* comment; with semicolon */ create table b (s text default \"\\\";\");\n" +
+				"# comment; here\n" +
+				"fail;\n" +
+				"create table c (id int);\n",
+			wantExecs: []string{
+				"create database `tenant_1`",
+				"use `tenant_1`",
+				"create table a (s text default 'it\\'s; fine')",
+				"/* comment; with semicolon */ create table b (s text default \"\\\";\")",
+				"fail",
+				"drop database `tenant_1`",
+			},
+		},
+		{
+			name:     "PostgreSQL",
+			switcher: &nagaya.PostgreSQLTenantSwitcher{},
+			template: "create function f() returns text as $body$ select 'a;b'; $body$ language sql;\n" +
+				"create table a (s text default 'C:\\', t text default E'it\\'s; fine', u text default $$;$$);\n" +
+				"fail;\n" +
+				"create table c (id int);\n",
+			wantExecs: []string{
+				`create schema "tenant_1"`,
+				`select set_config('search_path', $1, false) from pg_catalog.pg_namespace where nspname = $2`,
+				"create function f() returns text as $body$ select 'a;b'; $body$ language sql",
+				"create table a (s text default 'C:\\', t text default E'it\\'s; fine', u text default $$;$$)",
+				"fail",
+				`drop schema "tenant_1" cascade`,
+				"reset search_path",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			connector := &scriptConnector{}
+			ngy := nagaya.NewStd(openStubDBForTesting(t, connector), nagaya.WithTenantSwitcher(tc.switcher))
+			fsys := fstest.MapFS{"schema.sql": &fstest.MapFile{Data: []byte(tc.template)}}
+			err := ngy.CreateTenant(t.Context(), "tenant_1", nagaya.WithSchemaTemplate(fsys, "schema.sql"))
+			var applyErr *nagaya.ApplySchemaTemplateError
+			if !errors.As(err, &applyErr) {
+				t.Fatalf("expected ApplySchemaTemplateError but got: %v", err)
+			}
+			if got := connector.statements(); !slices.Equal(got, tc.wantExecs) {
+				t.Errorf("executed statements:\n\twant: %q\n\t got: %q", tc.wantExecs, got)
+			}
+		})
+	}
+}
+
+// scriptConnector records the executed statements and fails the one that is "fail".
+type scriptConnector struct {
+	mux   sync.Mutex
+	execs []string
+}
+
+func (c *scriptConnector) Connect(context.Context) (driver.Conn, error) {
+	return &scriptConn{connector: c}, nil
+}
+
+func (*scriptConnector) Driver() driver.Driver { return stubDriver{} }
+
+func (c *scriptConnector) statements() []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return slices.Clone(c.execs)
+}
+
+type scriptConn struct {
+	stubConn
+	connector *scriptConnector
+}
+
+func (c *scriptConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.connector.mux.Lock()
+	c.connector.execs = append(c.connector.execs, query)
+	c.connector.mux.Unlock()
+	if query == "fail" {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (*scriptConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	// the tenant always exists.
+	return &sliceRows{columns: []string{"count"}, values: [][]driver.Value{{int64(1)}}}, nil
+}
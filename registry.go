@@ -0,0 +1,181 @@
+package nagaya
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sync"
+)
+
+// TenantInfo describes a tenant known by the [TenantRegistry].
+type TenantInfo struct {
+	// Metadata is arbitrary attributes of the tenant.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Tenant   Tenant            `json:"tenant"`
+}
+
+// TenantRegistry knows which tenants exist.
+type TenantRegistry interface {
+	// Lookup returns the information of the tenant.
+	//
+	// It returns an [UnknownTenantError] if the tenant is not registered.
+	Lookup(ctx context.Context, tenant Tenant) (*TenantInfo, error)
+	// List returns all registered tenants.
+	List(ctx context.Context) ([]Tenant, error)
+	// Exists reports whether the tenant is registered.
+	Exists(ctx context.Context, tenant Tenant) (bool, error)
+}
+
+// InMemoryTenantRegistry is a [TenantRegistry] that holds tenants in memory.
+//
+// It is safe for concurrent use.
+type InMemoryTenantRegistry struct {
+	tenants map[Tenant]*TenantInfo
+	mux     sync.RWMutex
+}
+
+var _ TenantRegistry = (*InMemoryTenantRegistry)(nil)
+
+// NewInMemoryTenantRegistry returns a new [InMemoryTenantRegistry] that contains given tenants.
+func NewInMemoryTenantRegistry(infos ...TenantInfo) *InMemoryTenantRegistry {
+	r := &InMemoryTenantRegistry{tenants: make(map[Tenant]*TenantInfo, len(infos))}
+	for _, info := range infos {
+		r.Register(info)
+	}
+	return r
+}
+
+// Register adds the tenant to the registry or replaces the existing one.
+func (r *InMemoryTenantRegistry) Register(info TenantInfo) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.tenants[info.Tenant] = &info
+}
+
+// Unregister removes the tenant from the registry.
+func (r *InMemoryTenantRegistry) Unregister(tenant Tenant) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.tenants, tenant)
+}
+
+func (r *InMemoryTenantRegistry) Lookup(_ context.Context, tenant Tenant) (*TenantInfo, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	info, ok := r.tenants[tenant]
+	if !ok {
+		return nil, &UnknownTenantError{tenant: tenant}
+	}
+	ret := *info
+	return &ret, nil
+}
+
+func (r *InMemoryTenantRegistry) List(_ context.Context) ([]Tenant, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	tenants := make([]Tenant, 0, len(r.tenants))
+	for tenant := range r.tenants {
+		tenants = append(tenants, tenant)
+	}
+	slices.Sort(tenants)
+	return tenants, nil
+}
+
+func (r *InMemoryTenantRegistry) Exists(_ context.Context, tenant Tenant) (bool, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	_, ok := r.tenants[tenant]
+	return ok, nil
+}
+
+// LoadTenantRegistryFile returns a new [InMemoryTenantRegistry] that contains tenants listed in the file.
+//
+// The file must be a JSON array of [TenantInfo], for example:
+//
+//	[{"tenant": "tenant_1"}, {"tenant": "tenant_2", "metadata": {"plan": "enterprise"}}]
+func LoadTenantRegistryFile(fsys fs.FS, name string) (*InMemoryTenantRegistry, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var infos []TenantInfo
+	if err := json.Unmarshal(content, &infos); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant registry file %s: %w", name, err)
+	}
+	return NewInMemoryTenantRegistry(infos...), nil
+}
+
+// Queryer is a subset of [DBish] that only runs queries.
+type Queryer interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+// SQLTenantRegistry is a [TenantRegistry] that reads tenants from a database table.
+//
+// It does not support [TenantInfo.Metadata].
+type SQLTenantRegistry struct {
+	// DB is a database that has the table.
+	DB Queryer
+	// Table is a name of the table that stores tenants.
+	//
+	// It is embedded in the query as is, so it must not come from untrusted input.
+	Table string
+	// Column is a name of the column that stores tenants.
+	//
+	// It is embedded in the query as is, so it must not come from untrusted input.
+	Column string
+	// Placeholder is a bind parameter of the dialect.
+	//
+	// It defaults to "?" that is for MySQL. Use "$1" for PostgreSQL.
+	Placeholder string
+}
+
+var _ TenantRegistry = (*SQLTenantRegistry)(nil)
+
+func (r *SQLTenantRegistry) Lookup(ctx context.Context, tenant Tenant) (*TenantInfo, error) {
+	found, err := r.Exists(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, &UnknownTenantError{tenant: tenant}
+	}
+	return &TenantInfo{Tenant: tenant}, nil
+}
+
+func (r *SQLTenantRegistry) List(ctx context.Context) ([]Tenant, error) {
+	rows, err := r.DB.QueryContext(ctx, fmt.Sprintf("select %s from %s order by %s", r.Column, r.Table, r.Column))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var tenants []Tenant
+	for rows.Next() {
+		var tenant string
+		if err := rows.Scan(&tenant); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, Tenant(tenant))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+func (r *SQLTenantRegistry) Exists(ctx context.Context, tenant Tenant) (bool, error) {
+	placeholder := r.Placeholder
+	if placeholder == "" {
+		placeholder = "?"
+	}
+	var count int
+	query := fmt.Sprintf("select count(*) from %s where %s = %s", r.Table, r.Column, placeholder)
+	if err := r.DB.QueryRowContext(ctx, query, string(tenant)).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,129 @@
+package nagaya_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aereal/nagaya"
+)
+
+func TestInMemoryTenantRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := nagaya.NewInMemoryTenantRegistry(
+		nagaya.TenantInfo{Tenant: "tenant_2"},
+		nagaya.TenantInfo{Tenant: "tenant_1", Metadata: map[string]string{"plan": "enterprise"}},
+	)
+	registry.Register(nagaya.TenantInfo{Tenant: "tenant_3"})
+	registry.Unregister("tenant_2")
+	testTenantRegistry(t, registry, []nagaya.Tenant{"tenant_1", "tenant_3"}, "tenant_2")
+
+	info, err := registry.Lookup(t.Context(), "tenant_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan := info.Metadata["plan"]; plan != "enterprise" {
+		t.Errorf("unexpected metadata: %#v", info.Metadata)
+	}
+}
+
+func TestLoadTenantRegistryFile(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"tenants.json": &fstest.MapFile{Data: []byte(`[{"tenant":"tenant_1"},{"tenant":"tenant_2","metadata":{"plan":"free"}}]`)},
+		"broken.json":  &fstest.MapFile{Data: []byte(`{`)},
+	}
+	registry, err := nagaya.LoadTenantRegistryFile(fsys, "tenants.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testTenantRegistry(t, registry, []nagaya.Tenant{"tenant_1", "tenant_2"}, "tenant_3")
+
+	if _, err := nagaya.LoadTenantRegistryFile(fsys, "broken.json"); err == nil {
+		t.Error("expected an error for the broken file but got nil")
+	}
+}
+
+func TestSQLTenantRegistry(t *testing.T) {
+	t.Parallel()
+
+	db, err := openMySQLForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	registry := &nagaya.SQLTenantRegistry{DB: db, Table: "tenant_default.tenants", Column: "name"}
+	testTenantRegistry(t, registry, []nagaya.Tenant{"tenant_1", "tenant_2", "tenant_3"}, "tenant_non_existent")
+}
+
+func TestDo_withTenantRegistry(t *testing.T) {
+	t.Parallel()
+
+	ngy, err := newMySQLNagayaForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := nagaya.NewInMemoryTenantRegistry(nagaya.TenantInfo{Tenant: "tenant_1"})
+
+	var called bool
+	handler := func(context.Context) error {
+		called = true
+		return nil
+	}
+	decision := &nagaya.TenantDecisionResultChangeTenant{Tenant: nagaya.Tenant("tenant_2")}
+	err = nagaya.Do(t.Context(), ngy, handler, nagaya.WithTenantDecisionResult(decision), nagaya.WithTenantRegistry(registry))
+	var unknownErr *nagaya.UnknownTenantError
+	if !errors.As(err, &unknownErr) {
+		t.Fatalf("expected UnknownTenantError but got: %v", err)
+	}
+	if unknownErr.Tenant() != "tenant_2" {
+		t.Errorf("unexpected tenant: %s", unknownErr.Tenant())
+	}
+	if called {
+		t.Error("the handler must not be called for the unknown tenant")
+	}
+}
+
+func testTenantRegistry(t *testing.T, registry nagaya.TenantRegistry, wantTenants []nagaya.Tenant, unknown nagaya.Tenant) {
+	t.Helper()
+
+	ctx := t.Context()
+	tenants, err := registry.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tenants, wantTenants) {
+		t.Errorf("List():\n\twant: %v\n\t got: %v", wantTenants, tenants)
+	}
+	for _, tenant := range wantTenants {
+		found, err := registry.Exists(ctx, tenant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found {
+			t.Errorf("Exists(%s) must be true", tenant)
+		}
+		info, err := registry.Lookup(ctx, tenant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Tenant != tenant {
+			t.Errorf("Lookup(%s) returns unexpected tenant: %s", tenant, info.Tenant)
+		}
+	}
+	found, err := registry.Exists(ctx, unknown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Errorf("Exists(%s) must be false", unknown)
+	}
+	var unknownErr *nagaya.UnknownTenantError
+	if _, err := registry.Lookup(ctx, unknown); !errors.As(err, &unknownErr) {
+		t.Errorf("Lookup(%s) must return UnknownTenantError but got: %v", unknown, err)
+	}
+}
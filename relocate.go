@@ -0,0 +1,373 @@
+package nagaya
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TenantRelocator is an optional interface of the [TenantSwitcher] that can move the tenant between the databases.
+type TenantRelocator interface {
+	TenantProvisioner
+	// SetTenantReadOnly makes the tenant read-only or writable again.
+	SetTenantReadOnly(ctx context.Context, conn Connish, tenant Tenant, readOnly bool) error
+	// CopyTenant copies the tenant from the src connection to the dst connection.
+	//
+	// It must fail with [TenantAlreadyExistsError] if the tenant exists in the dst.
+	// created reports whether it has created the tenant in the dst even if it fails,
+	// so that the partial copy is dropped without touching the tenant that has existed before.
+	CopyTenant(ctx context.Context, src, dst Connish, tenant Tenant) (created bool, err error)
+}
+
+// RelocateTenant moves the tenant to the shard.
+//
+// The shards must be configured by [WithShards], the [TenantSwitcher] must implement [TenantRelocator]
+// and the [ShardResolver] given by [WithShardResolver] must implement [ShardUpdater].
+// The updater should persist the shards in the storage shared by all processes, otherwise the tenant is routed back to the source shard
+// after a restart or in other processes; for this reason [WithDropSource] is refused with [ErrVolatileShardUpdater] if the updater is [ShardMap].
+//
+// It proceeds as below:
+//
+//  1. stops binding new connections for the tenant; [Nagaya.BindConnection] waits for the duration given by [WithRelocationWait] and then fails with [ErrTenantRelocating].
+//     This gate only works in the process that calls RelocateTenant; other processes keep binding connections to the source shard
+//     until their [ShardResolver] returns the target shard, and their writes fail during the cut-over because the source is read-only.
+//  2. makes the tenant read-only in the source shard, so that the writes of the connections bound before fail instead of being lost.
+//  3. copies the tenant to the target shard.
+//  4. flips the shard of the tenant by [ShardUpdater.SetShard] and lets [Nagaya.BindConnection] proceed to the target shard.
+//
+// If it fails before the flip, the tenant is made writable again and the partial copy that the relocation has created is dropped.
+// The tenant in the source shard is kept read-only after the relocation unless [WithDropSource] is given.
+func (n *Nagaya[DB, Conn]) RelocateTenant(ctx context.Context, tenant Tenant, to string, opts ...RelocateTenantOption) (err error) {
+	ctx, span := n.tracer.Start(ctx, "Nagaya.RelocateTenant", trace.WithAttributes(attrTenant(tenant), KeyShard.String(to)))
+	defer func() { finishSpan(span, err) }()
+
+	var cfg relocateTenantConfig
+	for _, o := range opts {
+		o.applyRelocateTenantOption(&cfg)
+	}
+	relocator, ok := n.switcher.(TenantRelocator)
+	if !ok {
+		return ErrRelocationUnsupported
+	}
+	updater, ok := n.shardResolver.(ShardUpdater)
+	if !ok || len(n.shards) == 0 {
+		return ErrRelocationUnsupported
+	}
+	if _, volatile := updater.(*ShardMap); volatile && cfg.dropSource {
+		return ErrVolatileShardUpdater
+	}
+	if err := n.rule.Validate(tenant); err != nil {
+		return err
+	}
+	srcDB, from, err := n.dbFor(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+	dstDB, ok := n.shards[to]
+	if !ok {
+		return &UnknownShardError{shard: to}
+	}
+
+	release, err := n.relocations.enter(tenant)
+	if err != nil {
+		return err
+	}
+	defer release()
+	src, err := n.getConn(ctx, srcDB)
+	if err != nil {
+		return &ObtainConnectionError{err: err}
+	}
+	defer func() { _ = src.Close() }()
+	dst, err := n.getConn(ctx, dstDB)
+	if err != nil {
+		return &ObtainConnectionError{err: err}
+	}
+	defer func() { _ = dst.Close() }()
+
+	rel := &relocation{relocator: relocator, src: src, dst: dst, tenant: tenant, span: span}
+	if err := rel.cutOver(ctx, func() error { return updater.SetShard(ctx, tenant, to) }); err != nil {
+		return &RelocateTenantError{err: err, tenant: tenant, from: from, to: to}
+	}
+	if cfg.dropSource {
+		// the read-only database cannot be dropped.
+		if err := relocator.SetTenantReadOnly(ctx, src, tenant, false); err != nil {
+			return &RelocateTenantError{err: fmt.Errorf("failed to drop source: %w", err), tenant: tenant, from: from, to: to}
+		}
+		if err := relocator.DropTenant(ctx, src, tenant); err != nil {
+			return &RelocateTenantError{err: fmt.Errorf("failed to drop source: %w", err), tenant: tenant, from: from, to: to}
+		}
+		span.AddEvent("nagaya.relocation.source_dropped")
+	}
+	return nil
+}
+
+type relocation struct {
+	relocator TenantRelocator
+	src       Connish
+	dst       Connish
+	tenant    Tenant
+	span      trace.Span
+}
+
+// cutOver makes the source read-only, copies the tenant and then flips the shard.
+func (r *relocation) cutOver(ctx context.Context, flip func() error) error {
+	if err := r.relocator.SetTenantReadOnly(ctx, r.src, r.tenant, true); err != nil {
+		return err
+	}
+	r.span.AddEvent("nagaya.relocation.read_only")
+	if created, err := r.relocator.CopyTenant(ctx, r.src, r.dst, r.tenant); err != nil {
+		return r.rollback(ctx, err, created)
+	}
+	r.span.AddEvent("nagaya.relocation.copied")
+	if err := flip(); err != nil {
+		return r.rollback(ctx, err, true)
+	}
+	r.span.AddEvent("nagaya.relocation.flipped")
+	return nil
+}
+
+// rollback makes the source writable again and drops the partial copy if the relocation has created it.
+//
+// The tenant in the dst is kept if it has existed before, for example when the copy fails with [TenantAlreadyExistsError].
+func (r *relocation) rollback(ctx context.Context, cause error, dropCopy bool) error {
+	// the relocation may fail due to the cancellation, but the source must be writable anyway.
+	ctx = context.WithoutCancel(ctx)
+	errs := []error{cause}
+	if dropCopy {
+		var unknownTenantErr *UnknownTenantError
+		if err := r.relocator.DropTenant(ctx, r.dst, r.tenant); err != nil && !errors.As(err, &unknownTenantErr) {
+			errs = append(errs, fmt.Errorf("failed to drop the partial copy: %w", err))
+		}
+	}
+	if err := r.relocator.SetTenantReadOnly(ctx, r.src, r.tenant, false); err != nil {
+		errs = append(errs, fmt.Errorf("failed to make the source writable: %w", err))
+	}
+	r.span.AddEvent("nagaya.relocation.rolled_back")
+	return errors.Join(errs...)
+}
+
+// relocationGate holds the tenants under relocation.
+type relocationGate struct {
+	mux        sync.Mutex
+	relocating map[Tenant]chan struct{}
+}
+
+// enter marks the tenant is under relocation and returns the function to finish it.
+func (g *relocationGate) enter(tenant Tenant) (func(), error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.relocating == nil {
+		g.relocating = make(map[Tenant]chan struct{})
+	}
+	if _, ok := g.relocating[tenant]; ok {
+		return nil, ErrTenantRelocating
+	}
+	done := make(chan struct{})
+	g.relocating[tenant] = done
+	return func() {
+		g.mux.Lock()
+		defer g.mux.Unlock()
+		delete(g.relocating, tenant)
+		close(done)
+	}, nil
+}
+
+// wait blocks until the relocation of the tenant finishes up to the timeout.
+func (g *relocationGate) wait(ctx context.Context, tenant Tenant, timeout time.Duration) error {
+	g.mux.Lock()
+	done, ok := g.relocating[tenant]
+	g.mux.Unlock()
+	if !ok {
+		return nil
+	}
+	if timeout <= 0 {
+		return ErrTenantRelocating
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return ErrTenantRelocating
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var _ TenantRelocator = (*MySQLTenantSwitcher)(nil)
+
+// SetTenantReadOnly makes the database read-only by ALTER DATABASE ... READ ONLY that MySQL 8.0.22 or later supports.
+//
+// It waits for the transactions that have written the tenant to finish.
+func (s *MySQLTenantSwitcher) SetTenantReadOnly(ctx context.Context, conn Connish, tenant Tenant, readOnly bool) error {
+	value := "0"
+	if readOnly {
+		value = "1"
+	}
+	_, err := conn.ExecContext(ctx, "alter database "+quoteMySQLIdentifier(string(tenant))+" read only = "+value)
+	return err
+}
+
+// CopyTenant creates the database and its tables with the same definitions in the dst and copies all rows.
+//
+// The views, triggers and routines are not copied like [MySQLTenantSwitcher.RenameTenant].
+func (s *MySQLTenantSwitcher) CopyTenant(ctx context.Context, src, dst Connish, tenant Tenant) (bool, error) {
+	exists, err := tenantExists(ctx, dst, "?", tenant)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, &TenantAlreadyExistsError{tenant: tenant}
+	}
+	var spec TenantSpec
+	if err := src.QueryRowContext(ctx, "select default_character_set_name, default_collation_name from information_schema.schemata where schema_name = ?", string(tenant)).Scan(&spec.Charset, &spec.Collation); err != nil {
+		return false, err
+	}
+	tables, err := mysqlListTables(ctx, src, tenant)
+	if err != nil {
+		return false, err
+	}
+	ddl, err := mysqlCreateDatabaseDDL(tenant, &spec)
+	if err != nil {
+		return false, err
+	}
+	if _, err := dst.ExecContext(ctx, ddl); err != nil {
+		// the database may be created by others after the check above.
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrDBCreateExists {
+			return false, &TenantAlreadyExistsError{tenant: tenant}
+		}
+		return false, err
+	}
+	// the tables are created and filled in the alphabetical order regardless of the foreign keys.
+	if _, err := dst.ExecContext(ctx, "set session foreign_key_checks = 0"); err != nil {
+		return true, err
+	}
+	defer func() { _, _ = dst.ExecContext(context.WithoutCancel(ctx), "set session foreign_key_checks = 1") }()
+	for _, table := range tables {
+		if err := mysqlCopyTable(ctx, src, dst, tenant, table); err != nil {
+			return true, fmt.Errorf("failed to copy table %s: %w", table, err)
+		}
+	}
+	return true, nil
+}
+
+const (
+	// mysqlCopyBatchRows is the maximum number of the rows inserted by one statement.
+	mysqlCopyBatchRows = 500
+	// mysqlMaxPlaceholders is the maximum number of the placeholders in one prepared statement that MySQL accepts.
+	mysqlMaxPlaceholders = 65535
+	// mysqlCopyBatchBytes is the rough budget of the values in one statement to stay well below max_allowed_packet.
+	mysqlCopyBatchBytes = 1 << 20
+)
+
+func mysqlCopyTable(ctx context.Context, src, dst Connish, tenant Tenant, table string) error {
+	qualified := quoteMySQLIdentifier(string(tenant)) + "." + quoteMySQLIdentifier(table)
+	var name, ddl string
+	if err := src.QueryRowContext(ctx, "show create table "+qualified).Scan(&name, &ddl); err != nil {
+		return err
+	}
+	// the DDL has the unqualified table name, so qualify it with the tenant.
+	ddl = strings.Replace(ddl, "CREATE TABLE "+quoteMySQLIdentifier(table), "CREATE TABLE "+qualified, 1)
+	if _, err := dst.ExecContext(ctx, ddl); err != nil {
+		return err
+	}
+	columns, err := mysqlListInsertableColumns(ctx, src, tenant, table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteMySQLIdentifier(column)
+	}
+	columnList := strings.Join(quoted, ", ")
+	rows, err := src.QueryContext(ctx, "select "+columnList+" from "+qualified)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	rowPlaceholder := "(" + strings.Repeat("?, ", len(columns)-1) + "?)"
+	batchRows := min(mysqlCopyBatchRows, mysqlMaxPlaceholders/len(columns))
+	batch := make([]any, 0, batchRows*len(columns))
+	var batchBytes int
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		placeholders := strings.Repeat(rowPlaceholder+", ", len(batch)/len(columns)-1) + rowPlaceholder
+		if _, err := dst.ExecContext(ctx, "insert into "+qualified+" ("+columnList+") values "+placeholders, batch...); err != nil {
+			return err
+		}
+		batch, batchBytes = batch[:0], 0
+		return nil
+	}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dests := make([]any, len(columns))
+		for i := range values {
+			dests[i] = &values[i]
+		}
+		if err := rows.Scan(dests...); err != nil {
+			return err
+		}
+		batch = append(batch, values...)
+		for _, v := range values {
+			batchBytes += valueSize(v)
+		}
+		if len(batch) >= batchRows*len(columns) || batchBytes >= mysqlCopyBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// valueSize estimates the size of the scanned value in the statement.
+func valueSize(v any) int {
+	switch v := v.(type) {
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	default:
+		return 8
+	}
+}
+
+// mysqlListInsertableColumns lists the columns except the generated ones that cannot be inserted.
+func mysqlListInsertableColumns(ctx context.Context, conn Queryer, tenant Tenant, table string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "select column_name from information_schema.columns where table_schema = ? and table_name = ? and extra not like '%GENERATED%' order by ordinal_position", string(tenant), table)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
@@ -0,0 +1,440 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+// stubRelocator is a [nagaya.TenantRelocator] that records the calls and blocks in CopyTenant until proceed is closed.
+type stubRelocator struct {
+	failingSwitcher
+	copying chan struct{}
+	proceed chan struct{}
+	copyErr error
+	mux     sync.Mutex
+	calls   []string
+}
+
+func newStubRelocator() *stubRelocator {
+	return &stubRelocator{copying: make(chan struct{}), proceed: make(chan struct{})}
+}
+
+func (s *stubRelocator) record(call string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.calls = append(s.calls, call)
+}
+
+func (s *stubRelocator) recorded() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return slices.Clone(s.calls)
+}
+
+func (s *stubRelocator) SetTenantReadOnly(_ context.Context, _ nagaya.Connish, tenant nagaya.Tenant, readOnly bool) error {
+	s.record(fmt.Sprintf("SetTenantReadOnly(%s, %t)", tenant, readOnly))
+	return nil
+}
+
+func (s *stubRelocator) CopyTenant(ctx context.Context, _, _ nagaya.Connish, tenant nagaya.Tenant) (bool, error) {
+	s.record(fmt.Sprintf("CopyTenant(%s)", tenant))
+	close(s.copying)
+	select {
+	case <-s.proceed:
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+	// the copy has created the tenant unless it has existed before.
+	var alreadyExistsErr *nagaya.TenantAlreadyExistsError
+	return !errors.As(s.copyErr, &alreadyExistsErr), s.copyErr
+}
+
+func (s *stubRelocator) DropTenant(_ context.Context, _ nagaya.Connish, tenant nagaya.Tenant) error {
+	s.record(fmt.Sprintf("DropTenant(%s)", tenant))
+	return nil
+}
+
+// sharedShardMap is a [nagaya.ShardUpdater] that pretends to persist the shards in the shared storage.
+type sharedShardMap struct{ *nagaya.ShardMap }
+
+func newRelocatingNagayaForTesting(t *testing.T, relocator *stubRelocator, opts ...nagaya.NewOption) (*nagaya.Nagaya[*sql.DB, *sql.Conn], map[string]*sql.DB) {
+	t.Helper()
+
+	shards := map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{}), "b": openStubDBForTesting(t, stubConnector{})}
+	opts = append(opts,
+		nagaya.WithTenantSwitcher(relocator),
+		nagaya.WithShards(shards),
+		nagaya.WithShardResolver(sharedShardMap{nagaya.NewShardMap(&nagaya.StaticShardResolver{Default: "a"})}))
+	return nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), opts...), shards
+}
+
+func TestNagaya_RelocateTenant(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	relocator := newStubRelocator()
+	ngy, shards := newRelocatingNagayaForTesting(t, relocator)
+	relocated := make(chan error, 1)
+	go func() { relocated <- ngy.RelocateTenant(ctx, "tenant_1", "b") }()
+	<-relocator.copying
+
+	if _, err := ngy.BindConnection(nagaya.ContextWithRequestID(ctx, "req_1"), "tenant_1"); !errors.Is(err, nagaya.ErrTenantRelocating) {
+		t.Errorf("expected ErrTenantRelocating but got %v", err)
+	}
+	conn, err := ngy.BindConnection(nagaya.ContextWithRequestID(ctx, "req_2"), "tenant_2")
+	if err != nil {
+		t.Fatalf("other tenants must not be blocked: %v", err)
+	}
+	_ = conn.Close()
+	if err := ngy.RelocateTenant(ctx, "tenant_1", "b"); !errors.Is(err, nagaya.ErrTenantRelocating) {
+		t.Errorf("expected ErrTenantRelocating for the concurrent relocation but got %v", err)
+	}
+
+	close(relocator.proceed)
+	if err := <-relocated; err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"SetTenantReadOnly(tenant_1, true)", "CopyTenant(tenant_1)"}
+	if got := relocator.recorded(); !slices.Equal(got, want) {
+		t.Errorf("calls:\n\twant: %v\n\t got: %v", want, got)
+	}
+	conn, err = ngy.BindConnection(nagaya.ContextWithRequestID(ctx, "req_3"), "tenant_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	if inUse := shards["b"].Stats().InUse; inUse != 1 {
+		t.Errorf("the tenant must be bound to the new shard: %d connections in use", inUse)
+	}
+}
+
+func TestNagaya_RelocateTenant_wait(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	relocator := newStubRelocator()
+	ngy, shards := newRelocatingNagayaForTesting(t, relocator, nagaya.WithRelocationWait(time.Minute))
+	relocated := make(chan error, 1)
+	go func() { relocated <- ngy.RelocateTenant(ctx, "tenant_1", "b", nagaya.WithDropSource()) }()
+	<-relocator.copying
+
+	bound := make(chan error, 1)
+	go func() {
+		conn, err := ngy.BindConnection(nagaya.ContextWithRequestID(ctx, "req_1"), "tenant_1")
+		if err == nil {
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+		bound <- err
+	}()
+	select {
+	case err := <-bound:
+		t.Fatalf("BindConnection must wait for the relocation but returned: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(relocator.proceed)
+	if err := <-relocated; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-bound; err != nil {
+		t.Fatal(err)
+	}
+	if inUse := shards["b"].Stats().InUse; inUse != 1 {
+		t.Errorf("the tenant must be bound to the new shard: %d connections in use", inUse)
+	}
+	want := []string{"SetTenantReadOnly(tenant_1, true)", "CopyTenant(tenant_1)", "SetTenantReadOnly(tenant_1, false)", "DropTenant(tenant_1)"}
+	if got := relocator.recorded(); !slices.Equal(got, want) {
+		t.Errorf("calls:\n\twant: %v\n\t got: %v", want, got)
+	}
+}
+
+func TestNagaya_RelocateTenant_failure(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	relocator := newStubRelocator()
+	relocator.copyErr = errors.New("oops")
+	close(relocator.proceed)
+	ngy, shards := newRelocatingNagayaForTesting(t, relocator)
+	err := ngy.RelocateTenant(ctx, "tenant_1", "b")
+	var relocateErr *nagaya.RelocateTenantError
+	if !errors.As(err, &relocateErr) || !errors.Is(err, relocator.copyErr) {
+		t.Fatalf("expected RelocateTenantError but got %v", err)
+	}
+	if relocateErr.Tenant() != "tenant_1" || relocateErr.From() != "a" || relocateErr.To() != "b" {
+		t.Errorf("unexpected error: %v", relocateErr)
+	}
+	want := []string{"SetTenantReadOnly(tenant_1, true)", "CopyTenant(tenant_1)", "DropTenant(tenant_1)", "SetTenantReadOnly(tenant_1, false)"}
+	if got := relocator.recorded(); !slices.Equal(got, want) {
+		t.Errorf("calls:\n\twant: %v\n\t got: %v", want, got)
+	}
+	conn, err := ngy.BindConnection(nagaya.ContextWithRequestID(ctx, "req_1"), "tenant_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	if inUse := shards["a"].Stats().InUse; inUse != 1 {
+		t.Errorf("the tenant must stay in the old shard: %d connections in use", inUse)
+	}
+}
+
+func TestNagaya_RelocateTenant_targetExists(t *testing.T) {
+	t.Parallel()
+
+	relocator := newStubRelocator()
+	relocator.copyErr = &nagaya.TenantAlreadyExistsError{}
+	close(relocator.proceed)
+	ngy, _ := newRelocatingNagayaForTesting(t, relocator)
+	var alreadyExistsErr *nagaya.TenantAlreadyExistsError
+	if err := ngy.RelocateTenant(t.Context(), "tenant_1", "b"); !errors.As(err, &alreadyExistsErr) {
+		t.Fatalf("expected TenantAlreadyExistsError but got %v", err)
+	}
+	want := []string{"SetTenantReadOnly(tenant_1, true)", "CopyTenant(tenant_1)", "SetTenantReadOnly(tenant_1, false)"}
+	if got := relocator.recorded(); !slices.Equal(got, want) {
+		t.Errorf("the existing tenant in the target must not be dropped:\n\twant: %v\n\t got: %v", want, got)
+	}
+}
+
+func TestNagaya_RelocateTenant_invalid(t *testing.T) {
+	t.Parallel()
+
+	ngy, _ := newRelocatingNagayaForTesting(t, newStubRelocator())
+	var unknownShardErr *nagaya.UnknownShardError
+	if err := ngy.RelocateTenant(t.Context(), "tenant_1", "z"); !errors.As(err, &unknownShardErr) {
+		t.Errorf("expected UnknownShardError but got %v", err)
+	}
+	if err := ngy.RelocateTenant(t.Context(), "tenant_1", "a"); err != nil {
+		t.Errorf("relocating to the same shard must be no-op: %v", err)
+	}
+	unsharded := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithTenantSwitcher(newStubRelocator()))
+	if err := unsharded.RelocateTenant(t.Context(), "tenant_1", "a"); !errors.Is(err, nagaya.ErrRelocationUnsupported) {
+		t.Errorf("expected ErrRelocationUnsupported but got %v", err)
+	}
+	defaultResolver := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithTenantSwitcher(newStubRelocator()),
+		nagaya.WithShards(map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{}), "b": openStubDBForTesting(t, stubConnector{})}))
+	if err := defaultResolver.RelocateTenant(t.Context(), "tenant_1", "a"); !errors.Is(err, nagaya.ErrRelocationUnsupported) {
+		t.Errorf("expected ErrRelocationUnsupported but got %v", err)
+	}
+	volatile := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithTenantSwitcher(newStubRelocator()),
+		nagaya.WithShards(map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{}), "b": openStubDBForTesting(t, stubConnector{})}),
+		nagaya.WithShardResolver(nagaya.NewShardMap(&nagaya.StaticShardResolver{Default: "a"})))
+	if err := volatile.RelocateTenant(t.Context(), "tenant_1", "b", nagaya.WithDropSource()); !errors.Is(err, nagaya.ErrVolatileShardUpdater) {
+		t.Errorf("expected ErrVolatileShardUpdater but got %v", err)
+	}
+	// embedding the interface hides the methods of TenantRelocator.
+	switcher := struct{ nagaya.TenantSwitcher }{&failingSwitcher{}}
+	unsupported := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithTenantSwitcher(switcher),
+		nagaya.WithShards(map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{})}))
+	if err := unsupported.RelocateTenant(t.Context(), "tenant_1", "a"); !errors.Is(err, nagaya.ErrRelocationUnsupported) {
+		t.Errorf("expected ErrRelocationUnsupported but got %v", err)
+	}
+}
+
+// copySourceConnector serves a tenant that has one table of given columns and rows,
+// and records the number of the arguments of the inserts.
+type copySourceConnector struct {
+	columns int
+	rows    int
+	value   func(row, column int) driver.Value
+	mux     *sync.Mutex
+	inserts *[]int
+}
+
+func (c copySourceConnector) Connect(context.Context) (driver.Conn, error) {
+	return &copySourceConn{copySourceConnector: c}, nil
+}
+
+func (copySourceConnector) Driver() driver.Driver { return stubDriver{} }
+
+type copySourceConn struct {
+	stubConn
+	copySourceConnector
+}
+
+func (c *copySourceConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if strings.HasPrefix(query, "insert into") {
+		c.mux.Lock()
+		*c.inserts = append(*c.inserts, len(args))
+		c.mux.Unlock()
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *copySourceConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "count(*) from information_schema.schemata"):
+		return &sliceRows{columns: []string{"count"}, values: [][]driver.Value{{int64(0)}}}, nil
+	case strings.Contains(query, "default_character_set_name"):
+		return &sliceRows{columns: []string{"charset", "collation"}, values: [][]driver.Value{{"utf8mb4", "utf8mb4_bin"}}}, nil
+	case strings.Contains(query, "information_schema.tables"):
+		return &sliceRows{columns: []string{"table_name"}, values: [][]driver.Value{{"wide"}}}, nil
+	case strings.HasPrefix(query, "show create table"):
+		return &sliceRows{columns: []string{"Table", "Create Table"}, values: [][]driver.Value{{"wide", "CREATE TABLE `wide` ()"}}}, nil
+	case strings.Contains(query, "information_schema.columns"):
+		rows := &sliceRows{columns: []string{"column_name"}}
+		for i := range c.columns {
+			rows.values = append(rows.values, []driver.Value{fmt.Sprintf("c%d", i)})
+		}
+		return rows, nil
+	default:
+		rows := &sliceRows{columns: make([]string, c.columns)}
+		for i := range c.rows {
+			row := make([]driver.Value, c.columns)
+			for j := range row {
+				row[j] = c.value(i, j)
+			}
+			rows.values = append(rows.values, row)
+		}
+		return rows, nil
+	}
+}
+
+type sliceRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *sliceRows) Columns() []string { return r.columns }
+
+func (r *sliceRows) Close() error { return nil }
+
+func (r *sliceRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestMySQLTenantSwitcher_CopyTenant_batches(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		columns      int
+		rows         int
+		value        func(row, column int) driver.Value
+		wantInserts  int
+		maxBatchRows int
+	}{
+		{
+			name:         "wide table",
+			columns:      200,
+			rows:         400,
+			value:        func(row, column int) driver.Value { return int64(row * column) },
+			wantInserts:  2,
+			maxBatchRows: 65535 / 200,
+		},
+		{
+			name:         "large rows",
+			columns:      2,
+			rows:         10,
+			value:        func(int, int) driver.Value { return make([]byte, 200<<10) },
+			wantInserts:  4,
+			maxBatchRows: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var inserts []int
+			connector := copySourceConnector{columns: tc.columns, rows: tc.rows, value: tc.value, mux: new(sync.Mutex), inserts: &inserts}
+			src, err := openStubDBForTesting(t, connector).Conn(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = src.Close() })
+			dst, err := openStubDBForTesting(t, connector).Conn(t.Context())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = dst.Close() })
+
+			if _, err := new(nagaya.MySQLTenantSwitcher).CopyTenant(t.Context(), src, dst, "tenant_1"); err != nil {
+				t.Fatal(err)
+			}
+			if len(inserts) != tc.wantInserts {
+				t.Errorf("inserts:\n\twant: %d\n\t got: %d (%v)", tc.wantInserts, len(inserts), inserts)
+			}
+			var copied int
+			for _, args := range inserts {
+				if args > 65535 {
+					t.Errorf("the insert has %d placeholders over the limit of MySQL", args)
+				}
+				if rows := args / tc.columns; rows > tc.maxBatchRows {
+					t.Errorf("the insert has %d rows over %d", rows, tc.maxBatchRows)
+				}
+				copied += args / tc.columns
+			}
+			if copied != tc.rows {
+				t.Errorf("copied rows:\n\twant: %d\n\t got: %d", tc.rows, copied)
+			}
+		})
+	}
+}
+
+const envTestDB2DSN = "TEST_DB2_DSN"
+
+func TestNagaya_RelocateTenant_mysql(t *testing.T) {
+	t.Parallel()
+
+	db1, err := openMySQLForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsn2 := os.Getenv(envTestDB2DSN)
+	if dsn2 == "" {
+		t.Fatalf("%s is required", envTestDB2DSN)
+	}
+	db2, err := sql.Open("mysql", dsn2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ngy := nagaya.NewStd(db1,
+		nagaya.WithShards(map[string]*sql.DB{"a": db1, "b": db2}),
+		nagaya.WithShardResolver(sharedShardMap{nagaya.NewShardMap(&nagaya.StaticShardResolver{Default: "a"})}))
+
+	ctx := t.Context()
+	tenant := nagaya.Tenant(fmt.Sprintf("tenant_reloc_%d", time.Now().UnixNano()))
+	if err := ngy.CreateTenant(ctx, tenant, nagaya.WithSchemaTemplate(os.DirFS("testdata"), "tenant_schema.sql")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ngy.DropTenant(context.WithoutCancel(ctx), tenant) })
+	if err := insertUser(ctx, ngy, tenant); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ngy.RelocateTenant(ctx, tenant, "b", nagaya.WithDropSource()); err != nil {
+		t.Fatal(err)
+	}
+	if err := insertUser(ctx, ngy, tenant); err != nil {
+		t.Errorf("failed to insert a user into the relocated tenant: %s", err)
+	}
+	var count int
+	if err := db2.QueryRowContext(ctx, "select count(*) from "+string(tenant)+".users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("the relocated tenant has %d users, want 2", count)
+	}
+	var exists int
+	if err := db1.QueryRowContext(ctx, "select count(*) from information_schema.schemata where schema_name = ?", string(tenant)).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists != 0 {
+		t.Error("the source tenant must be dropped")
+	}
+}
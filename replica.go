@@ -0,0 +1,200 @@
+package nagaya
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ReplicaPolicy chooses a replica to read from.
+type ReplicaPolicy interface {
+	// Pick returns the index of the replica to use.
+	//
+	// The replicas are never empty.
+	Pick(replicas []DBish) int
+}
+
+// ReplicaPolicyFunc is a function that implements [ReplicaPolicy].
+type ReplicaPolicyFunc func(replicas []DBish) int
+
+var _ ReplicaPolicy = (ReplicaPolicyFunc)(nil)
+
+func (f ReplicaPolicyFunc) Pick(replicas []DBish) int { return f(replicas) }
+
+// RoundRobin returns a [ReplicaPolicy] that chooses the replicas in turn.
+func RoundRobin() ReplicaPolicy { return new(roundRobin) }
+
+type roundRobin struct{ next atomic.Uint64 }
+
+func (p *roundRobin) Pick(replicas []DBish) int {
+	return int((p.next.Add(1) - 1) % uint64(len(replicas)))
+}
+
+// LeastInUse returns a [ReplicaPolicy] that chooses the replica that has the fewest connections in use.
+//
+// The numbers of the connections are taken from [DBish.Stats].
+func LeastInUse() ReplicaPolicy {
+	return ReplicaPolicyFunc(func(replicas []DBish) int {
+		var ret int
+		least := replicas[0].Stats().InUse
+		for i, replica := range replicas[1:] {
+			if inUse := replica.Stats().InUse; inUse < least {
+				ret, least = i+1, inUse
+			}
+		}
+		return ret
+	})
+}
+
+// WithReplicas tells the Nagaya to read from given replicas by [Nagaya.ObtainReadConnection].
+//
+// The replica is chosen by the [ReplicaPolicy] given by [WithReplicaPolicy].
+// The replicas are of the DB given to [New], so they cannot be used with [WithShards]; use [WithShardReplicas] instead.
+// The type parameter DB must be the same as the Nagaya's one, otherwise [New] panics.
+func WithReplicas[DB DBish](replicas ...DB) NewOption {
+	return &optReplicas{replicas: map[string][]DB{"": replicas}}
+}
+
+// WithShardReplicas tells the Nagaya to read from the replicas of the shard that the tenant belongs to by [Nagaya.ObtainReadConnection].
+//
+// The keys are the names of the shards given by [WithShards].
+// The tenants of the shards without replicas read from the primary.
+// The type parameter DB must be the same as the Nagaya's one, otherwise [New] panics.
+func WithShardReplicas[DB DBish](replicas map[string][]DB) NewOption {
+	return &optReplicas{replicas: maps.Clone(replicas)}
+}
+
+// newReplicas builds the replicas of each shard given by the options.
+//
+// The replicas of the DB given to [New] are keyed by the empty name.
+// The replicas are typed as any because [NewOption] cannot know the Nagaya's type parameters.
+func newReplicas[DB DBish](given []any, shards map[string]DB) map[string][]DB {
+	replicas := make(map[string][]DB)
+	for _, g := range given {
+		m, ok := g.(map[string][]DB)
+		if !ok {
+			var db DB
+			panic(fmt.Sprintf("nagaya: the replicas cannot be used for the Nagaya of %T", db))
+		}
+		for shard, dbs := range m {
+			if len(dbs) == 0 {
+				continue
+			}
+			if shard == "" && len(shards) > 0 {
+				panic("nagaya: WithReplicas cannot be used with WithShards; use WithShardReplicas instead")
+			}
+			if _, ok := shards[shard]; shard != "" && !ok {
+				panic(fmt.Sprintf("nagaya: the replicas are given for the unknown shard %q", shard))
+			}
+			replicas[shard] = append(replicas[shard], dbs...)
+		}
+	}
+	return replicas
+}
+
+// ObtainReadConnection returns a connection of the replica that bound to the current tenant.
+//
+// The connection is obtained on the first call in the request and the same one is returned after that.
+// It is released by [Nagaya.ReleaseReadConnection], which [Middleware] and [Do] call by themselves.
+//
+// The replica is chosen from the ones of the shard that the connection is bound to by [Nagaya.BindConnection],
+// and the tenant is switched within the timeout given to it.
+// If no replicas are configured for the shard, or the chosen replica fails to obtain a connection or to switch the tenant,
+// it falls back to the primary connection returned by [Nagaya.ObtainConnection] for the rest of the request.
+// The cancellation of the context and the failures of the hooks are returned as is instead of falling back.
+func (n *Nagaya[DB, Conn]) ObtainReadConnection(ctx context.Context) (conn Conn, err error) {
+	ctx, span := n.tracer.Start(ctx, "Nagaya.ObtainReadConnection")
+	defer func() { finishSpan(span, err) }()
+
+	reqID, ok := RequestIDFromContext(ctx)
+	if !ok {
+		return conn, ErrNoConnectionBound
+	}
+	tenant, _ := TenantFromContext(ctx)
+	span.SetAttributes(attrRequestID(reqID), attrTenant(tenant))
+	n.mux.RLock()
+	rc, ok := n.readConns[reqID]
+	primary, bound := n.conns[reqID]
+	b := n.bindings[reqID]
+	n.mux.RUnlock()
+	shard := b.shard
+	replicas := n.replicas[shard]
+	if len(replicas) == 0 {
+		return n.ObtainConnection(ctx)
+	}
+	if ok {
+		return rc.conn, nil
+	}
+	if !bound {
+		// the read connection must be bound within the lifecycle of the primary one to be released.
+		return conn, ErrNoConnectionBound
+	}
+
+	idx := n.pickReplica(shard)
+	span.SetAttributes(KeyReplica.Int(idx))
+	if shard != "" {
+		span.SetAttributes(KeyShard.String(shard))
+	}
+	rc.conn, err = n.bind(ctx, replicas[idx], &HookEvent[Conn]{Tenant: tenant, RequestID: reqID}, b.timeout)
+	if err != nil {
+		var hookErr *HookError
+		if ctx.Err() != nil || errors.As(err, &hookErr) {
+			return conn, err
+		}
+		span.AddEvent("nagaya.replica.fallback", trace.WithAttributes(KeyReplica.Int(idx)))
+		b.logger.LogAttrs(ctx, slog.LevelWarn, "replica is unavailable; fall back to the primary", slog.Int(string(KeyReplica), idx), slog.Any("error", err))
+		// the fallback is remembered so that the replica is not tried again in the request.
+		rc = readConn[Conn]{conn: primary, primary: true}
+	}
+	n.mux.Lock()
+	if existing, ok := n.readConns[reqID]; ok {
+		// another goroutine of the request has bound one in the meantime.
+		n.mux.Unlock()
+		_ = n.closeReadConn(ctx, rc)
+		return existing.conn, nil
+	}
+	n.readConns[reqID] = rc
+	n.mux.Unlock()
+	return rc.conn, nil
+}
+
+// readConn is the connection returned by ObtainReadConnection.
+type readConn[Conn Connish] struct {
+	conn Conn
+	// primary tells the conn is the primary one that the replica falls back to, which must not be restored nor closed with the read connection.
+	primary bool
+}
+
+// ReleaseReadConnection restores and closes the replica connection bound for the request if any.
+func (n *Nagaya[DB, Conn]) ReleaseReadConnection(ctx context.Context, requestID string) error {
+	n.mux.Lock()
+	rc, ok := n.readConns[requestID]
+	delete(n.readConns, requestID)
+	n.mux.Unlock()
+	if !ok {
+		return nil
+	}
+	return n.closeReadConn(ctx, rc)
+}
+
+func (n *Nagaya[DB, Conn]) closeReadConn(ctx context.Context, rc readConn[Conn]) error {
+	if rc.primary {
+		return nil
+	}
+	err := n.RestoreConnection(ctx, rc.conn)
+	_ = rc.conn.Close()
+	return err
+}
+
+func (n *Nagaya[DB, Conn]) pickReplica(shard string) int {
+	idx := n.replicaPolicy.Pick(n.replicaDBs[shard])
+	if idx < 0 || idx >= len(n.replicaDBs[shard]) {
+		return 0
+	}
+	return idx
+}
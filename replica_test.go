@@ -0,0 +1,339 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aereal/nagaya"
+)
+
+type failingConnector struct{ err error }
+
+func (c failingConnector) Connect(context.Context) (driver.Conn, error) { return nil, c.err }
+
+func (failingConnector) Driver() driver.Driver { return stubDriver{} }
+
+func TestRoundRobin(t *testing.T) {
+	t.Parallel()
+
+	replicas := []nagaya.DBish{openStubDBForTesting(t, stubConnector{}), openStubDBForTesting(t, stubConnector{}), openStubDBForTesting(t, stubConnector{})}
+	policy := nagaya.RoundRobin()
+	var got []int
+	for range 5 {
+		got = append(got, policy.Pick(replicas))
+	}
+	if want := []int{0, 1, 2, 0, 1}; !slices.Equal(want, got) {
+		t.Errorf("want: %v, got: %v", want, got)
+	}
+}
+
+func TestLeastInUse(t *testing.T) {
+	t.Parallel()
+
+	replicas := []nagaya.DBish{openStubDBForTesting(t, stubConnector{}), openStubDBForTesting(t, stubConnector{}), openStubDBForTesting(t, stubConnector{})}
+	for _, i := range []int{0, 0, 2} {
+		conn, err := replicas[i].(*sql.DB).Conn(t.Context())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+	}
+	if got := nagaya.LeastInUse().Pick(replicas); got != 1 {
+		t.Errorf("want: 1, got: %d", got)
+	}
+}
+
+func TestNagaya_ObtainReadConnection(t *testing.T) {
+	t.Parallel()
+
+	errConnect := errors.New("replica is down")
+	testCases := []struct {
+		name        string
+		replicas    []*sql.DB
+		wantPrimary bool
+	}{
+		{
+			name:     "replica",
+			replicas: []*sql.DB{openStubDBForTesting(t, stubConnector{})},
+		},
+		{
+			name:        "no replicas",
+			wantPrimary: true,
+		},
+		{
+			name:        "fallback to primary",
+			replicas:    []*sql.DB{openStubDBForTesting(t, failingConnector{err: errConnect})},
+			wantPrimary: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithTenantSwitcher(&failingSwitcher{}), nagaya.WithReplicas(tc.replicas...))
+			err := nagaya.Do(t.Context(), ngy, func(ctx context.Context) error {
+				primary, err := ngy.ObtainConnection(ctx)
+				if err != nil {
+					return err
+				}
+				read, err := ngy.ObtainReadConnection(ctx)
+				if err != nil {
+					return err
+				}
+				if (read == primary) != tc.wantPrimary {
+					t.Errorf("read connection is primary: %t", read == primary)
+				}
+				again, err := ngy.ObtainReadConnection(ctx)
+				if err != nil {
+					return err
+				}
+				if !tc.wantPrimary && again != read {
+					t.Error("expected the same read connection is returned in the request")
+				}
+				return nil
+			}, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_1"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, replica := range tc.replicas {
+				if inUse := replica.Stats().InUse; inUse != 0 {
+					t.Errorf("replica %d has %d connections in use", i, inUse)
+				}
+			}
+		})
+	}
+}
+
+func TestNagaya_ObtainReadConnection_fallback(t *testing.T) {
+	t.Parallel()
+
+	errConnect := errors.New("replica is down")
+	errHook := errors.New("hook failed")
+	testCases := []struct {
+		name        string
+		replica     *countingConnector
+		hook        nagaya.HookFunc[*sql.Conn]
+		cancel      bool
+		wantPrimary bool
+		wantErr     error
+	}{
+		{name: "replica is down", replica: &countingConnector{err: errConnect}, wantPrimary: true},
+		{name: "canceled", replica: &countingConnector{}, cancel: true, wantErr: context.Canceled},
+		{
+			name:    "hook failed",
+			replica: &countingConnector{},
+			hook:    failAfter(1, errHook),
+			wantErr: errHook,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := []nagaya.NewOption{nagaya.WithTenantSwitcher(&failingSwitcher{}), nagaya.WithReplicas(openStubDBForTesting(t, tc.replica))}
+			if tc.hook != nil {
+				opts = append(opts, nagaya.BeforeBind(tc.hook))
+			}
+			ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), opts...)
+			err := nagaya.Do(t.Context(), ngy, func(ctx context.Context) error {
+				primary, err := ngy.ObtainConnection(ctx)
+				if err != nil {
+					return err
+				}
+				if tc.cancel {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithCancel(ctx)
+					cancel()
+				}
+				for range 2 {
+					read, err := ngy.ObtainReadConnection(ctx)
+					if !errors.Is(err, tc.wantErr) {
+						t.Fatalf("error:\n\twant: %v\n\t got: %v", tc.wantErr, err)
+					}
+					if (read == primary) != tc.wantPrimary {
+						t.Errorf("read connection is primary: %t", read == primary)
+					}
+				}
+				return nil
+			}, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_1"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantPrimary {
+				if got := tc.replica.count.Load(); got != 1 {
+					t.Errorf("the replica is tried %d times, want once", got)
+				}
+			}
+		})
+	}
+}
+
+func TestNagaya_ObtainReadConnection_timeout(t *testing.T) {
+	t.Parallel()
+
+	timeout := 100 * time.Millisecond
+	switcher := &deadlineSwitcher{}
+	ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithTenantSwitcher(switcher), nagaya.WithReplicas(openStubDBForTesting(t, stubConnector{})))
+	err := nagaya.Do(t.Context(), ngy, func(ctx context.Context) error {
+		_, err := ngy.ObtainReadConnection(ctx)
+		return err
+	}, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_1"}), nagaya.WithTimeout(timeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(switcher.remaining) != 2 {
+		t.Fatalf("expected the primary and the replica are switched but got %d switches", len(switcher.remaining))
+	}
+	for i, remaining := range switcher.remaining {
+		if remaining > timeout {
+			t.Errorf("switch #%d: the deadline is %s later, want within %s", i, remaining, timeout)
+		}
+	}
+}
+
+// failAfter returns a hook that succeeds n times and then fails, so that only the replica fails after the primary is bound.
+func failAfter(n int32, err error) nagaya.HookFunc[*sql.Conn] {
+	var calls atomic.Int32
+	return func(context.Context, *nagaya.HookEvent[*sql.Conn]) error {
+		if calls.Add(1) > n {
+			return err
+		}
+		return nil
+	}
+}
+
+type countingConnector struct {
+	err   error
+	count atomic.Int32
+}
+
+func (c *countingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.count.Add(1)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return stubConnector{}.Connect(ctx)
+}
+
+func (*countingConnector) Driver() driver.Driver { return stubDriver{} }
+
+type deadlineSwitcher struct {
+	nagaya.MySQLTenantSwitcher
+	remaining []time.Duration
+	mux       sync.Mutex
+}
+
+func (s *deadlineSwitcher) Switch(ctx context.Context, conn nagaya.Execer, tenant nagaya.Tenant) error {
+	deadline, _ := ctx.Deadline()
+	s.mux.Lock()
+	s.remaining = append(s.remaining, time.Until(deadline))
+	s.mux.Unlock()
+	return s.MySQLTenantSwitcher.Switch(ctx, conn, tenant)
+}
+
+func TestNagaya_ObtainReadConnection_notBound(t *testing.T) {
+	t.Parallel()
+
+	ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithReplicas(openStubDBForTesting(t, stubConnector{})))
+	ctx := nagaya.ContextWithRequestID(nagaya.WithTenant(t.Context(), "tenant_1"), "req_1")
+	if _, err := ngy.ObtainReadConnection(ctx); !errors.Is(err, nagaya.ErrNoConnectionBound) {
+		t.Errorf("want: %v, got: %v", nagaya.ErrNoConnectionBound, err)
+	}
+}
+
+func TestWithReplicas_mismatchedDB(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected New panics")
+		}
+	}()
+	_ = nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), nagaya.WithReplicas[nagaya.DBish](openStubDBForTesting(t, stubConnector{})))
+}
+
+func TestNagaya_ObtainReadConnection_shards(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		tenant      nagaya.Tenant
+		wantReplica string
+	}{
+		{name: "shard a", tenant: "tenant_1", wantReplica: "a"},
+		{name: "shard b", tenant: "tenant_2", wantReplica: "b"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			replicas := map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{}), "b": openStubDBForTesting(t, stubConnector{})}
+			ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}),
+				nagaya.WithTenantSwitcher(&failingSwitcher{}),
+				nagaya.WithShards(map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{}), "b": openStubDBForTesting(t, stubConnector{})}),
+				nagaya.WithShardResolver(&nagaya.StaticShardResolver{Shards: map[nagaya.Tenant]string{"tenant_1": "a", "tenant_2": "b"}}),
+				nagaya.WithShardReplicas(map[string][]*sql.DB{"a": {replicas["a"]}, "b": {replicas["b"]}}))
+			err := nagaya.Do(t.Context(), ngy, func(ctx context.Context) error {
+				if _, err := ngy.ObtainReadConnection(ctx); err != nil {
+					return err
+				}
+				for name, replica := range replicas {
+					wantInUse := 0
+					if name == tc.wantReplica {
+						wantInUse = 1
+					}
+					if inUse := replica.Stats().InUse; inUse != wantInUse {
+						t.Errorf("replica of shard %s has %d connections in use, want %d", name, inUse, wantInUse)
+					}
+				}
+				return nil
+			}, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: tc.tenant}))
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestWithReplicas_invalidShards(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		opts []nagaya.NewOption
+	}{
+		{
+			name: "replicas of the primary with shards",
+			opts: []nagaya.NewOption{
+				nagaya.WithShards(map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{})}),
+				nagaya.WithReplicas(openStubDBForTesting(t, stubConnector{})),
+			},
+		},
+		{
+			name: "replicas of unknown shard",
+			opts: []nagaya.NewOption{
+				nagaya.WithShards(map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{})}),
+				nagaya.WithShardReplicas(map[string][]*sql.DB{"b": {openStubDBForTesting(t, stubConnector{})}}),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				if recover() == nil {
+					t.Error("expected New panics")
+				}
+			}()
+			_ = nagaya.NewStd(openStubDBForTesting(t, stubConnector{}), tc.opts...)
+		})
+	}
+}
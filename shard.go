@@ -0,0 +1,219 @@
+package nagaya
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// DefaultShardMetadataKey is the key of [TenantInfo.Metadata] that [RegistryShardResolver] takes the shard from by default.
+const DefaultShardMetadataKey = "shard"
+
+// ShardResolver decides the shard that the tenant belongs to.
+type ShardResolver interface {
+	// ResolveShard returns the name of the shard that the tenant belongs to.
+	ResolveShard(ctx context.Context, tenant Tenant) (string, error)
+}
+
+// ShardResolverFunc is a function that implements [ShardResolver].
+type ShardResolverFunc func(ctx context.Context, tenant Tenant) (string, error)
+
+var _ ShardResolver = (ShardResolverFunc)(nil)
+
+func (f ShardResolverFunc) ResolveShard(ctx context.Context, tenant Tenant) (string, error) {
+	return f(ctx, tenant)
+}
+
+// StaticShardResolver is a [ShardResolver] that maps the tenants to the shards by the fixed map.
+type StaticShardResolver struct {
+	// Shards maps the tenants to the shard names.
+	Shards map[Tenant]string
+	// Default is the shard of the tenants that are not in Shards.
+	//
+	// If it is empty, such tenants are rejected with [ErrNoShard].
+	Default string
+}
+
+var _ ShardResolver = (*StaticShardResolver)(nil)
+
+func (r *StaticShardResolver) ResolveShard(_ context.Context, tenant Tenant) (string, error) {
+	if shard, ok := r.Shards[tenant]; ok {
+		return shard, nil
+	}
+	if r.Default != "" {
+		return r.Default, nil
+	}
+	return "", ErrNoShard
+}
+
+// HashShardResolver returns a [ShardResolver] that spreads the tenants over the shards by their hash.
+//
+// It uses rendezvous hashing, so adding a shard moves only the tenants that the new shard takes
+// and the order of the shards does not matter.
+func HashShardResolver(shards ...string) ShardResolver {
+	shards = slices.Clone(shards)
+	return ShardResolverFunc(func(_ context.Context, tenant Tenant) (string, error) {
+		var (
+			ret  string
+			best uint64
+		)
+		for _, shard := range shards {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(shard))
+			_, _ = h.Write([]byte{0})
+			_, _ = h.Write([]byte(tenant))
+			if score := mix64(h.Sum64()); ret == "" || score > best {
+				ret, best = shard, score
+			}
+		}
+		if ret == "" {
+			return "", ErrNoShard
+		}
+		return ret, nil
+	})
+}
+
+// mix64 scatters the bits of the FNV hash that are correlated for similar inputs like "tenant_1" and "tenant_2".
+//
+// It is the finalizer of MurmurHash3.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// RegistryShardResolver is a [ShardResolver] that takes the shard from [TenantInfo.Metadata] in the [TenantRegistry].
+type RegistryShardResolver struct {
+	Registry TenantRegistry
+	// MetadataKey is the key of the shard in the metadata.
+	//
+	// [DefaultShardMetadataKey] is used if it is empty.
+	MetadataKey string
+}
+
+var _ ShardResolver = (*RegistryShardResolver)(nil)
+
+func (r *RegistryShardResolver) ResolveShard(ctx context.Context, tenant Tenant) (string, error) {
+	info, err := r.Registry.Lookup(ctx, tenant)
+	if err != nil {
+		return "", err
+	}
+	key := r.MetadataKey
+	if key == "" {
+		key = DefaultShardMetadataKey
+	}
+	shard, ok := info.Metadata[key]
+	if !ok || shard == "" {
+		return "", ErrNoShard
+	}
+	return shard, nil
+}
+
+// ShardUpdater is a [ShardResolver] that can change the shard of the tenant.
+//
+// [Nagaya.RelocateTenant] requires the resolver to implement it to flip the shard of the tenant.
+type ShardUpdater interface {
+	ShardResolver
+	// SetShard changes the shard of the tenant.
+	//
+	// The following ResolveShard calls must return the new shard once it returns.
+	SetShard(ctx context.Context, tenant Tenant, shard string) error
+}
+
+// ShardMap is a [ShardUpdater] that overrides the shards decided by the base resolver.
+//
+// The overrides are held in memory and lost on restart, and other processes never see them;
+// the relocated tenants are routed back to the source shard there.
+// So it is only for the tests and the single-process deployments, and [Nagaya.RelocateTenant] refuses [WithDropSource] with it.
+// Deployments with multiple processes should implement [ShardUpdater] that stores the shards in the shared storage such as the [TenantRegistry].
+//
+// It is safe for concurrent use.
+type ShardMap struct {
+	base   ShardResolver
+	shards map[Tenant]string
+	mux    sync.RWMutex
+}
+
+var _ ShardUpdater = (*ShardMap)(nil)
+
+// NewShardMap returns a new [ShardMap] that falls back to the base resolver.
+//
+// If the base is nil, the tenants not set are rejected with [ErrNoShard].
+func NewShardMap(base ShardResolver) *ShardMap {
+	return &ShardMap{base: base, shards: make(map[Tenant]string)}
+}
+
+func (m *ShardMap) ResolveShard(ctx context.Context, tenant Tenant) (string, error) {
+	m.mux.RLock()
+	shard, ok := m.shards[tenant]
+	m.mux.RUnlock()
+	if ok {
+		return shard, nil
+	}
+	if m.base == nil {
+		return "", ErrNoShard
+	}
+	return m.base.ResolveShard(ctx, tenant)
+}
+
+func (m *ShardMap) SetShard(_ context.Context, tenant Tenant, shard string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.shards[tenant] = shard
+	return nil
+}
+
+// WithShards tells the Nagaya to obtain the connections for the tenants from the shards.
+//
+// The shard of the tenant is decided by the [ShardResolver] given by [WithShardResolver],
+// or by [HashShardResolver] of all shards if not given.
+// [Nagaya.RelocateTenant] requires the resolver given by [WithShardResolver] to implement [ShardUpdater].
+// The DB given to [New] is still used when no tenant is bound.
+// The type parameter DB must be the same as the Nagaya's one, otherwise [New] panics.
+func WithShards[DB DBish](shards map[string]DB) NewOption {
+	return &optShards{shards: maps.Clone(shards)}
+}
+
+// newShards builds the shards given by the options.
+//
+// The shards are typed as any because [NewOption] cannot know the Nagaya's type parameters.
+func newShards[DB DBish](given []any) map[string]DB {
+	if len(given) == 0 {
+		return nil
+	}
+	shards := make(map[string]DB)
+	for _, g := range given {
+		s, ok := g.(map[string]DB)
+		if !ok {
+			var db DB
+			panic(fmt.Sprintf("nagaya: the shards cannot be used for the Nagaya of %T", db))
+		}
+		maps.Copy(shards, s)
+	}
+	return shards
+}
+
+// dbFor returns the DB of the shard that the tenant belongs to and the name of the shard.
+//
+// If no shards are configured, it returns the DB given to [New] and an empty name.
+func (n *Nagaya[DB, Conn]) dbFor(ctx context.Context, tenant Tenant) (DB, string, error) {
+	if len(n.shards) == 0 {
+		return n.db, "", nil
+	}
+	var zero DB
+	shard, err := n.shardResolver.ResolveShard(ctx, tenant)
+	if err != nil {
+		return zero, "", &ResolveShardError{err: err, tenant: tenant}
+	}
+	db, ok := n.shards[shard]
+	if !ok {
+		return zero, "", &ResolveShardError{err: &UnknownShardError{shard: shard}, tenant: tenant}
+	}
+	return db, shard, nil
+}
@@ -0,0 +1,213 @@
+package nagaya_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aereal/nagaya"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStaticShardResolver(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		resolver  *nagaya.StaticShardResolver
+		tenant    nagaya.Tenant
+		wantShard string
+		wantErr   error
+	}{
+		{name: "ok", resolver: &nagaya.StaticShardResolver{Shards: map[nagaya.Tenant]string{"tenant_1": "a"}}, tenant: "tenant_1", wantShard: "a"},
+		{name: "default", resolver: &nagaya.StaticShardResolver{Default: "b"}, tenant: "tenant_1", wantShard: "b"},
+		{name: "no shard", resolver: &nagaya.StaticShardResolver{}, tenant: "tenant_1", wantErr: nagaya.ErrNoShard},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			shard, err := tc.resolver.ResolveShard(t.Context(), tc.tenant)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("error:\n\twant: %v\n\t got: %v", tc.wantErr, err)
+			}
+			if shard != tc.wantShard {
+				t.Errorf("shard:\n\twant: %q\n\t got: %q", tc.wantShard, shard)
+			}
+		})
+	}
+}
+
+func TestHashShardResolver(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	resolver := nagaya.HashShardResolver("a", "b", "c")
+	reversed := nagaya.HashShardResolver("c", "b", "a")
+	grown := nagaya.HashShardResolver("a", "b", "c", "d")
+	counts := make(map[string]int)
+	for i := range 1000 {
+		tenant := nagaya.Tenant(fmt.Sprintf("tenant_%d", i))
+		shard, err := resolver.ResolveShard(ctx, tenant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[shard]++
+		if got, _ := reversed.ResolveShard(ctx, tenant); got != shard {
+			t.Errorf("%s: the order of the shards changes the shard from %s to %s", tenant, shard, got)
+		}
+		if got, _ := grown.ResolveShard(ctx, tenant); got != shard && got != "d" {
+			t.Errorf("%s: adding a shard moves the tenant from %s to %s", tenant, shard, got)
+		}
+	}
+	for _, shard := range []string{"a", "b", "c"} {
+		if counts[shard] < 200 {
+			t.Errorf("shard %s has only %d tenants", shard, counts[shard])
+		}
+	}
+	if _, err := nagaya.HashShardResolver().ResolveShard(ctx, "tenant_1"); !errors.Is(err, nagaya.ErrNoShard) {
+		t.Errorf("want: %v, got: %v", nagaya.ErrNoShard, err)
+	}
+}
+
+func TestRegistryShardResolver(t *testing.T) {
+	t.Parallel()
+
+	registry := nagaya.NewInMemoryTenantRegistry(
+		nagaya.TenantInfo{Tenant: "tenant_1", Metadata: map[string]string{"shard": "a"}},
+		nagaya.TenantInfo{Tenant: "tenant_2", Metadata: map[string]string{"cluster": "b"}},
+		nagaya.TenantInfo{Tenant: "tenant_3"},
+	)
+	var unknownTenantErr *nagaya.UnknownTenantError
+	testCases := []struct {
+		name      string
+		resolver  *nagaya.RegistryShardResolver
+		tenant    nagaya.Tenant
+		wantShard string
+		wantErr   any
+	}{
+		{name: "ok", resolver: &nagaya.RegistryShardResolver{Registry: registry}, tenant: "tenant_1", wantShard: "a"},
+		{name: "metadata key", resolver: &nagaya.RegistryShardResolver{Registry: registry, MetadataKey: "cluster"}, tenant: "tenant_2", wantShard: "b"},
+		{name: "no metadata", resolver: &nagaya.RegistryShardResolver{Registry: registry}, tenant: "tenant_3", wantErr: nagaya.ErrNoShard},
+		{name: "unknown tenant", resolver: &nagaya.RegistryShardResolver{Registry: registry}, tenant: "tenant_4", wantErr: &unknownTenantErr},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			shard, err := tc.resolver.ResolveShard(t.Context(), tc.tenant)
+			switch want := tc.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			case error:
+				if !errors.Is(err, want) {
+					t.Errorf("error:\n\twant: %v\n\t got: %v", want, err)
+				}
+			default:
+				if !errors.As(err, want) {
+					t.Errorf("error: want %T but got %v", want, err)
+				}
+			}
+			if shard != tc.wantShard {
+				t.Errorf("shard:\n\twant: %q\n\t got: %q", tc.wantShard, shard)
+			}
+		})
+	}
+}
+
+func TestNagaya_BindConnection_shards(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		tenant    nagaya.Tenant
+		wantShard string
+		wantErr   error
+	}{
+		{name: "shard a", tenant: "tenant_1", wantShard: "a"},
+		{name: "shard b", tenant: "tenant_2", wantShard: "b"},
+		{name: "unknown shard", tenant: "tenant_3", wantErr: nagaya.ErrNoShard},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			shards := map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{}), "b": openStubDBForTesting(t, stubConnector{})}
+			resolver := &nagaya.StaticShardResolver{Shards: map[nagaya.Tenant]string{"tenant_1": "a", "tenant_2": "b"}}
+			ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}),
+				nagaya.WithTenantSwitcher(&failingSwitcher{}), nagaya.WithTracerProvider(tp),
+				nagaya.WithShards(shards), nagaya.WithShardResolver(resolver))
+			conn, err := ngy.BindConnection(nagaya.ContextWithRequestID(t.Context(), "req_1"), tc.tenant)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("error:\n\twant: %v\n\t got: %v", tc.wantErr, err)
+			}
+			if err != nil {
+				var resolveErr *nagaya.ResolveShardError
+				if !errors.As(err, &resolveErr) || resolveErr.Tenant() != tc.tenant {
+					t.Errorf("expected ResolveShardError but got %v", err)
+				}
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+			for name, db := range shards {
+				wantInUse := 0
+				if name == tc.wantShard {
+					wantInUse = 1
+				}
+				if inUse := db.Stats().InUse; inUse != wantInUse {
+					t.Errorf("shard %s has %d connections in use, want %d", name, inUse, wantInUse)
+				}
+			}
+			var gotShard string
+			for _, span := range recorder.Ended() {
+				if span.Name() != "Nagaya.BindConnection" {
+					continue
+				}
+				for _, attr := range span.Attributes() {
+					if attr.Key == nagaya.KeyShard {
+						gotShard = attr.Value.AsString()
+					}
+				}
+			}
+			if gotShard != tc.wantShard {
+				t.Errorf("shard attribute:\n\twant: %q\n\t got: %q", tc.wantShard, gotShard)
+			}
+		})
+	}
+}
+
+func TestNagaya_BindConnection_unknownShard(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ngy := nagaya.NewStd(openStubDBForTesting(t, stubConnector{}),
+		nagaya.WithTenantSwitcher(&failingSwitcher{}), nagaya.WithTracerProvider(tp),
+		nagaya.WithShards(map[string]*sql.DB{"a": openStubDBForTesting(t, stubConnector{})}),
+		nagaya.WithShardResolver(&nagaya.StaticShardResolver{Default: "z"}))
+	_, err := ngy.BindConnection(nagaya.ContextWithRequestID(t.Context(), "req_1"), "tenant_1")
+	var unknownShardErr *nagaya.UnknownShardError
+	if !errors.As(err, &unknownShardErr) || unknownShardErr.Shard() != "z" {
+		t.Errorf("expected UnknownShardError but got %v", err)
+	}
+	var found bool
+	for _, span := range recorder.Ended() {
+		if span.Name() != "Nagaya.BindConnection" {
+			continue
+		}
+		found = true
+		if span.Status().Code != codes.Error {
+			t.Errorf("the span must be marked as an error but the status is %v", span.Status())
+		}
+	}
+	if !found {
+		t.Error("no Nagaya.BindConnection span is recorded")
+	}
+}
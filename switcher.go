@@ -0,0 +1,110 @@
+package nagaya
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TenantSwitcher changes the tenant that a database connection points to.
+//
+// The implementation decides how the tenants are isolated, for example database per tenant or schema per tenant.
+type TenantSwitcher interface {
+	// Switch changes the current tenant of the connection to given tenant.
+	Switch(ctx context.Context, conn Execer, tenant Tenant) error
+	// Reset restores the connection to the state before any tenant is switched.
+	Reset(ctx context.Context, conn Execer) error
+}
+
+// switchTenant changes the tenant of the connection within the timeout.
+func switchTenant(ctx context.Context, switcher TenantSwitcher, conn Execer, tenant Tenant, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := switcher.Switch(ctx, conn, tenant); err != nil {
+		return &ChangeTenantError{err: err, tenant: tenant}
+	}
+	return nil
+}
+
+// MySQLTenantSwitcher is a [TenantSwitcher] that treats each MySQL database as a tenant.
+//
+// It is used by default.
+type MySQLTenantSwitcher struct {
+	// DefaultTenant is a database that the connection is restored to.
+	//
+	// MySQL cannot deselect the current database, so Reset returns [ErrNoDefaultTenant] if it is empty
+	// and then the connection is discarded instead of being returned to the pool.
+	DefaultTenant Tenant
+}
+
+var _ TenantSwitcher = (*MySQLTenantSwitcher)(nil)
+
+// mysqlErrBadDB is the error number of MySQL that tells the database does not exist.
+const mysqlErrBadDB = 1049
+
+// Switch changes the current database of the connection.
+//
+// It returns an [UnknownTenantError] if the database does not exist.
+func (s *MySQLTenantSwitcher) Switch(ctx context.Context, conn Execer, tenant Tenant) error {
+	_, err := conn.ExecContext(ctx, "use "+quoteMySQLIdentifier(string(tenant)))
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrBadDB {
+		return &UnknownTenantError{err: err, tenant: tenant}
+	}
+	return err
+}
+
+func (s *MySQLTenantSwitcher) Reset(ctx context.Context, conn Execer) error {
+	if s.DefaultTenant == "" {
+		return ErrNoDefaultTenant
+	}
+	return s.Switch(ctx, conn, s.DefaultTenant)
+}
+
+func quoteMySQLIdentifier(ident string) string {
+	return "`" + strings.ReplaceAll(ident, "`", "``") + "`"
+}
+
+// PostgreSQLTenantSwitcher is a [TenantSwitcher] that treats each PostgreSQL schema as a tenant.
+//
+// It changes the search_path of the connection so that unqualified table names are resolved against the tenant schema.
+type PostgreSQLTenantSwitcher struct {
+	// SharedSchemas are schemas that are appended to the search_path after the tenant schema.
+	//
+	// It is useful for the tables shared by all tenants such as "public".
+	SharedSchemas []string
+}
+
+var _ TenantSwitcher = (*PostgreSQLTenantSwitcher)(nil)
+
+// Switch changes the search_path of the connection.
+//
+// PostgreSQL accepts the missing schemas in the search_path without any error such as invalid_schema_name (3F000),
+// so the path is set only if the tenant schema exists and it returns an [UnknownTenantError] otherwise.
+func (s *PostgreSQLTenantSwitcher) Switch(ctx context.Context, conn Execer, tenant Tenant) error {
+	schemas := make([]string, 0, len(s.SharedSchemas)+1)
+	schemas = append(schemas, quotePostgreSQLIdentifier(string(tenant)))
+	for _, schema := range s.SharedSchemas {
+		schemas = append(schemas, quotePostgreSQLIdentifier(schema))
+	}
+	ret, err := conn.ExecContext(ctx, "select set_config('search_path', $1, false) from pg_catalog.pg_namespace where nspname = $2", strings.Join(schemas, ", "), string(tenant))
+	if err != nil {
+		return err
+	}
+	if n, err := ret.RowsAffected(); err == nil && n == 0 {
+		return &UnknownTenantError{tenant: tenant}
+	}
+	return nil
+}
+
+func (s *PostgreSQLTenantSwitcher) Reset(ctx context.Context, conn Execer) error {
+	_, err := conn.ExecContext(ctx, "reset search_path")
+	return err
+}
+
+func quotePostgreSQLIdentifier(ident string) string {
+	return `"` + strings.ReplaceAll(ident, `"`, `""`) + `"`
+}
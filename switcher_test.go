@@ -0,0 +1,147 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/aereal/nagaya"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestPostgreSQLTenantSwitcher(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		switcher    *nagaya.PostgreSQLTenantSwitcher
+		tenant      nagaya.Tenant
+		wantSchemas string
+	}{
+		{
+			name:        "tenant only",
+			switcher:    &nagaya.PostgreSQLTenantSwitcher{},
+			tenant:      "tenant_1",
+			wantSchemas: "{tenant_1}",
+		},
+		{
+			name:        "with shared schema",
+			switcher:    &nagaya.PostgreSQLTenantSwitcher{SharedSchemas: []string{"public"}},
+			tenant:      "tenant_2",
+			wantSchemas: "{tenant_2,public}",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ngy, err := newPostgreSQLNagayaForTesting(nagaya.WithTenantSwitcher(tc.switcher))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decision := &nagaya.TenantDecisionResultChangeTenant{Tenant: tc.tenant}
+			handler := func(ctx context.Context) (string, error) {
+				conn, err := ngy.ObtainConnection(ctx)
+				if err != nil {
+					return "", err
+				}
+				if _, err := conn.ExecContext(ctx, "insert into users default values"); err != nil {
+					return "", fmt.Errorf("failed to insert user record: %w", err)
+				}
+				var schemas string
+				if err := conn.QueryRowContext(ctx, "select current_schemas(false)::text").Scan(&schemas); err != nil {
+					return "", err
+				}
+				return schemas, nil
+			}
+			got, err := nagaya.Yield(t.Context(), ngy, handler, nagaya.WithTenantDecisionResult(decision))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.wantSchemas {
+				t.Errorf("current_schemas:\n\twant: %s\n\t got: %s", tc.wantSchemas, got)
+			}
+		})
+	}
+}
+
+func TestTenantSwitcher_unknownTenant(t *testing.T) {
+	t.Parallel()
+
+	errAccessDenied := &mysql.MySQLError{Number: 1044, Message: "Access denied for user 'app'@'%' to database 'tenant_1'"}
+	testCases := []struct {
+		name        string
+		switcher    nagaya.TenantSwitcher
+		conn        execerFunc
+		wantUnknown bool
+		wantErr     error
+	}{
+		{
+			name:     "MySQL/unknown database",
+			switcher: &nagaya.MySQLTenantSwitcher{},
+			conn: func() (sql.Result, error) {
+				return nil, &mysql.MySQLError{Number: 1049, Message: "Unknown database 'tenant_1'"}
+			},
+			wantUnknown: true,
+		},
+		{
+			name:     "MySQL/other error",
+			switcher: &nagaya.MySQLTenantSwitcher{},
+			conn:     func() (sql.Result, error) { return nil, errAccessDenied },
+			wantErr:  errAccessDenied,
+		},
+		{
+			name:        "PostgreSQL/unknown schema",
+			switcher:    &nagaya.PostgreSQLTenantSwitcher{},
+			conn:        func() (sql.Result, error) { return driver.RowsAffected(0), nil },
+			wantUnknown: true,
+		},
+		{
+			name:     "PostgreSQL/ok",
+			switcher: &nagaya.PostgreSQLTenantSwitcher{},
+			conn:     func() (sql.Result, error) { return driver.RowsAffected(1), nil },
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.switcher.Switch(t.Context(), tc.conn, "tenant_1")
+			var unknownErr *nagaya.UnknownTenantError
+			if got := errors.As(err, &unknownErr); got != tc.wantUnknown {
+				t.Fatalf("expected UnknownTenantError=%v but got: %v", tc.wantUnknown, err)
+			}
+			if tc.wantUnknown && unknownErr.Tenant() != "tenant_1" {
+				t.Errorf("Tenant(): want=tenant_1 got=%s", unknownErr.Tenant())
+			}
+			if !tc.wantUnknown && !errors.Is(err, tc.wantErr) {
+				t.Errorf("error:\n\twant: %v\n\t got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+type execerFunc func() (sql.Result, error)
+
+func (f execerFunc) ExecContext(context.Context, string, ...any) (sql.Result, error) { return f() }
+
+const envTestPostgreSQLDSN = "TEST_PG_DSN"
+
+var errPostgreSQLDSNRequired = fmt.Errorf("%s is required", envTestPostgreSQLDSN)
+
+func newPostgreSQLNagayaForTesting(opts ...nagaya.NewOption) (*nagaya.Nagaya[*sql.DB, *sql.Conn], error) {
+	dsn := os.Getenv(envTestPostgreSQLDSN)
+	if dsn == "" {
+		return nil, errPostgreSQLDSNRequired
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return nagaya.NewStd(db, opts...), nil
+}
@@ -0,0 +1,55 @@
+package nagaya
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultTenantCharset is a set of characters that the tenant can contain by default.
+	DefaultTenantCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"
+	// DefaultTenantMaxLength is the maximum length of the tenant by default.
+	//
+	// It is the shorter one of the maximum identifier length of MySQL and PostgreSQL.
+	DefaultTenantMaxLength = 63
+)
+
+var defaultTenantRule = &TenantRule{Charset: DefaultTenantCharset, MaxLength: DefaultTenantMaxLength}
+
+// TenantRule is a rule that the tenant must satisfy before it reaches the database.
+type TenantRule struct {
+	// Charset is a set of characters that the tenant can contain.
+	Charset string
+	// MaxLength is the maximum length of the tenant in characters.
+	//
+	// Zero means no limit.
+	MaxLength int
+}
+
+// Validate returns an [InvalidTenantError] if the tenant does not satisfy the rule.
+func (r *TenantRule) Validate(tenant Tenant) error {
+	s := string(tenant)
+	if s == "" {
+		return &InvalidTenantError{tenant: tenant, reason: "empty"}
+	}
+	if !utf8.ValidString(s) {
+		return &InvalidTenantError{tenant: tenant, reason: "not a valid UTF-8 string"}
+	}
+	if r.MaxLength > 0 && utf8.RuneCountInString(s) > r.MaxLength {
+		return &InvalidTenantError{tenant: tenant, reason: "too long"}
+	}
+	for _, c := range s {
+		if !strings.ContainsRune(r.Charset, c) {
+			return &InvalidTenantError{tenant: tenant, reason: "contains disallowed character " + strconv.QuoteRune(c)}
+		}
+	}
+	return nil
+}
+
+// Validate returns an [InvalidTenantError] if the tenant does not satisfy the default rule.
+//
+// The default rule allows only [DefaultTenantCharset] and up to [DefaultTenantMaxLength] characters.
+func (t Tenant) Validate() error {
+	return defaultTenantRule.Validate(t)
+}
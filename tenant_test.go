@@ -0,0 +1,63 @@
+package nagaya_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aereal/nagaya"
+)
+
+func TestTenant_Validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		tenant  nagaya.Tenant
+		wantErr string
+	}{
+		{tenant: "tenant_1"},
+		{tenant: nagaya.Tenant(strings.Repeat("a", nagaya.DefaultTenantMaxLength))},
+		{tenant: "", wantErr: `invalid tenant "": empty`},
+		{tenant: nagaya.Tenant(strings.Repeat("a", nagaya.DefaultTenantMaxLength+1)), wantErr: `invalid tenant "` + strings.Repeat("a", nagaya.DefaultTenantMaxLength+1) + `": too long`},
+		{tenant: "x; drop database y", wantErr: `invalid tenant "x; drop database y": contains disallowed character ';'`},
+		{tenant: "tenant`1", wantErr: "invalid tenant \"tenant`1\": contains disallowed character '`'"},
+		{tenant: "\xff", wantErr: `invalid tenant "\xff": not a valid UTF-8 string`},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.tenant), func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.tenant.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error but got nil")
+			}
+			if !errors.Is(err, nagaya.ErrInvalidTenant) {
+				t.Errorf("expected ErrInvalidTenant but got %T", err)
+			}
+			if got := err.Error(); got != tc.wantErr {
+				t.Errorf("error message:\n\twant: %q\n\t got: %q", tc.wantErr, got)
+			}
+		})
+	}
+}
+
+func TestTenantRule_Validate(t *testing.T) {
+	t.Parallel()
+
+	rule := &nagaya.TenantRule{Charset: "abc-", MaxLength: 4}
+	if err := rule.Validate("a-bc"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := rule.Validate("a-bcc"); !errors.Is(err, nagaya.ErrInvalidTenant) {
+		t.Errorf("expected too long tenant is rejected but got: %v", err)
+	}
+	if err := rule.Validate("a_b"); !errors.Is(err, nagaya.ErrInvalidTenant) {
+		t.Errorf("expected a tenant contains disallowed character is rejected but got: %v", err)
+	}
+}
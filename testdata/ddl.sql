@@ -21,3 +21,11 @@ use tenant_3;
 create table if not exists users (
   id bigint unsigned auto_increment primary key
 ) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+use tenant_default;
+
+create table if not exists tenants (
+  name varchar(64) not null primary key
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+insert into tenants (name) values ('tenant_1'), ('tenant_2'), ('tenant_3');
@@ -0,0 +1,17 @@
+create schema tenant_1;
+
+create table if not exists tenant_1.users (
+  id bigserial primary key
+);
+
+create schema tenant_2;
+
+create table if not exists tenant_2.users (
+  id bigserial primary key
+);
+
+create schema tenant_3;
+
+create table if not exists tenant_3.users (
+  id bigserial primary key
+);
@@ -0,0 +1,9 @@
+-- the schema applied to each tenant created in tests
+create table if not exists users (
+  id bigint unsigned auto_increment primary key
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
+
+create table if not exists posts (
+  id bigint unsigned auto_increment primary key,
+  title varchar(255) not null default 'untitled; draft'
+) ENGINE=INNODB DEFAULT CHARSET=utf8mb4 collate=utf8mb4_unicode_ci;
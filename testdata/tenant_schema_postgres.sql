@@ -0,0 +1,9 @@
+-- the schema applied to each tenant created in tests
+create table if not exists users (
+  id bigserial primary key
+);
+
+create table if not exists posts (
+  id bigserial primary key,
+  title varchar(255) not null default 'untitled; draft'
+);
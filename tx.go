@@ -0,0 +1,198 @@
+package nagaya
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type txCtxKey struct{}
+
+func contextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txCtxKey{}, tx)
+}
+
+// TxFromContext extracts a transaction that begun by [DoTx], [YieldTx] or [Middleware] with [WithTransaction] in the context.
+//
+// If no transaction is in the context, the second return value is a false.
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txCtxKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+// DoTx runs a handler in a transaction on the database connection that bound for the determined tenant.
+//
+// The transaction can be taken by [TxFromContext] in the handler.
+// It is committed if the handler returns nil, and rolled back if the handler returns an error or panics.
+// The handler may commit or roll back the transaction by itself, and then it is left as is.
+// The options of the transaction can be given by [WithTxOptions].
+//
+// If the tenant is not changed, the transaction begins on the DB.
+func DoTx[DB DBish, Conn Connish](ctx context.Context, n *Nagaya[DB, Conn], handler func(context.Context) error, opts ...DoOption) error {
+	d := newDoer(n, nil, opts...)
+	d.handler = func(ctx context.Context) error {
+		return n.runTx(ctx, d.txBeginner(), d.txOptions, func(ctx context.Context, _ *txFinisher) (bool, error) {
+			err := handler(ctx)
+			return err == nil, err
+		})
+	}
+	return d.do(ctx)
+}
+
+// YieldTx returns a value from a yielder function that runs in a transaction like [DoTx].
+func YieldTx[V any, DB DBish, Conn Connish](ctx context.Context, n *Nagaya[DB, Conn], yielder func(context.Context) (V, error), opts ...DoOption) (V, error) {
+	var ret V
+	handler := func(ctx context.Context) error {
+		var err error
+		ret, err = yielder(ctx)
+		return err
+	}
+	if err := DoTx(ctx, n, handler, opts...); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
+
+// ObtainTx returns a transaction that begun for the current request by [Middleware] with [WithTransaction].
+//
+// It also returns the transaction begun by [DoTx] and [YieldTx].
+func (n *Nagaya[DB, Conn]) ObtainTx(ctx context.Context) (tx *sql.Tx, err error) {
+	_, span := n.tracer.Start(ctx, "Nagaya.ObtainTx")
+	defer func() { finishSpan(span, err) }()
+
+	tx, ok := TxFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTxBound
+	}
+	return tx, nil
+}
+
+type txBeginner interface {
+	BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
+}
+
+// runTx runs fn in a transaction and commits it if fn tells to commit, or rolls back it otherwise.
+//
+// fn can finish the transaction earlier by the finisher; then what fn tells is ignored.
+func (n *Nagaya[DB, Conn]) runTx(ctx context.Context, beginner txBeginner, opts *sql.TxOptions, fn func(context.Context, *txFinisher) (commit bool, err error)) (err error) {
+	ctx, span := n.tracer.Start(ctx, "Nagaya.Tx")
+	defer func() { finishSpan(span, err) }()
+
+	tx, err := beginner.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	finisher := &txFinisher{span: span, tx: tx}
+	defer func() {
+		if rec := recover(); rec != nil {
+			if !finisher.finished {
+				_ = rollbackTx(span, tx)
+			}
+			err = fmt.Errorf("panic in transaction: %v", rec)
+			panic(rec)
+		}
+	}()
+	commit, err := fn(contextWithTx(ctx, tx), finisher)
+	if finisher.finished {
+		return err
+	}
+	if err != nil || !commit {
+		if rbErr := rollbackTx(span, tx); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return commitTx(span, tx)
+}
+
+// txFinisher finishes the transaction of runTx before fn returns.
+type txFinisher struct {
+	span     trace.Span
+	tx       *sql.Tx
+	finished bool
+}
+
+func (f *txFinisher) commit() error {
+	f.finished = true
+	return commitTx(f.span, f.tx)
+}
+
+func commitTx(span trace.Span, tx *sql.Tx) error {
+	err := tx.Commit()
+	span.AddEvent("nagaya.tx.commit")
+	if errors.Is(err, sql.ErrTxDone) {
+		// the handler may commit or roll back the transaction by itself.
+		return nil
+	}
+	return err
+}
+
+func rollbackTx(span trace.Span, tx *sql.Tx) error {
+	err := tx.Rollback()
+	span.AddEvent("nagaya.tx.rollback")
+	if errors.Is(err, sql.ErrTxDone) {
+		// the handler may commit or roll back the transaction by itself.
+		return nil
+	}
+	return err
+}
+
+// txResponseWriter commits the transaction before the successful response is sent,
+// so that the client never sees the success of the write that is not committed.
+//
+// If the commit fails, the response of the handler is discarded and the failure is left to the error handler.
+type txResponseWriter struct {
+	http.ResponseWriter
+	finisher  *txFinisher
+	status    int
+	commitErr error
+}
+
+// decide commits the transaction if the status is not an error and tells whether the response can be sent.
+func (w *txResponseWriter) decide(status int) bool {
+	if w.status != 0 {
+		return w.commitErr == nil
+	}
+	w.status = status
+	if status < http.StatusBadRequest {
+		if err := w.finisher.commit(); err != nil {
+			w.commitErr = fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}
+	return w.commitErr == nil
+}
+
+func (w *txResponseWriter) WriteHeader(status int) {
+	if w.status != 0 || !w.decide(status) {
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *txResponseWriter) Write(b []byte) (int, error) {
+	if !w.decide(http.StatusOK) {
+		return 0, w.commitErr
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *txResponseWriter) Flush() {
+	if !w.decide(http.StatusOK) {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the original ResponseWriter for [http.ResponseController].
+func (w *txResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// sent tells the response of the handler has been sent to the client.
+func (w *txResponseWriter) sent() bool {
+	return w.status != 0 && w.commitErr == nil
+}
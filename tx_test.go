@@ -0,0 +1,307 @@
+package nagaya_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aereal/nagaya"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDoTx(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+	testCases := []struct {
+		name       string
+		opts       []nagaya.DoOption
+		handler    func(ctx context.Context) error
+		wantErr    error
+		wantPanic  bool
+		wantEvents []string
+	}{
+		{
+			name:       "commit",
+			handler:    func(context.Context) error { return nil },
+			wantEvents: []string{"nagaya.tx.commit"},
+		},
+		{
+			name:       "rollback",
+			handler:    func(context.Context) error { return errHandler },
+			wantErr:    errHandler,
+			wantEvents: []string{"nagaya.tx.rollback"},
+		},
+		{
+			name:       "rollback on panic",
+			handler:    func(context.Context) error { panic("oops") },
+			wantPanic:  true,
+			wantEvents: []string{"nagaya.tx.rollback"},
+		},
+		{
+			name: "handler commits by itself",
+			handler: func(ctx context.Context) error {
+				tx, _ := nagaya.TxFromContext(ctx)
+				if err := tx.Commit(); err != nil {
+					return err
+				}
+				return errHandler
+			},
+			wantErr:    errHandler,
+			wantEvents: []string{"nagaya.tx.rollback"},
+		},
+		{
+			name: "handler commits by itself and succeeds",
+			handler: func(ctx context.Context) error {
+				tx, _ := nagaya.TxFromContext(ctx)
+				return tx.Commit()
+			},
+			wantEvents: []string{"nagaya.tx.commit"},
+		},
+		{
+			name: "handler rolls back by itself and succeeds",
+			handler: func(ctx context.Context) error {
+				tx, _ := nagaya.TxFromContext(ctx)
+				return tx.Rollback()
+			},
+			wantEvents: []string{"nagaya.tx.commit"},
+		},
+		{
+			name:       "no tenant change",
+			opts:       []nagaya.DoOption{nagaya.WithTenantDecisionResult(nagaya.TenantDecisionResultNoChange{})},
+			handler:    func(context.Context) error { return nil },
+			wantEvents: []string{"nagaya.tx.commit"},
+		},
+		{
+			name:    "tx options",
+			opts:    []nagaya.DoOption{nagaya.WithTxOptions(&sql.TxOptions{Isolation: sql.LevelSerializable})},
+			handler: func(context.Context) error { return nil },
+			// the stub driver does not support the isolation levels.
+			wantErr: errAny,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			db := sql.OpenDB(stubConnector{})
+			t.Cleanup(func() { _ = db.Close() })
+			ngy := nagaya.NewStd(db, nagaya.WithTenantSwitcher(&failingSwitcher{}), nagaya.WithTracerProvider(tp))
+			opts := append([]nagaya.DoOption{nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_1"})}, tc.opts...)
+
+			var gotTx bool
+			handler := func(ctx context.Context) error {
+				_, gotTx = nagaya.TxFromContext(ctx)
+				return tc.handler(ctx)
+			}
+			var err error
+			func() {
+				defer func() {
+					if rec := recover(); (rec != nil) != tc.wantPanic {
+						t.Errorf("panic: %v", rec)
+					}
+				}()
+				err = nagaya.DoTx(t.Context(), ngy, handler, opts...)
+			}()
+			switch {
+			case tc.wantErr == errAny:
+				if err == nil {
+					t.Error("expected an error")
+				}
+			case !errors.Is(err, tc.wantErr):
+				t.Errorf("error:\n\twant: %v\n\t got: %v", tc.wantErr, err)
+			}
+			if tc.wantErr == errAny {
+				return
+			}
+			if !gotTx {
+				t.Error("expected a transaction is in the context")
+			}
+			if gotEvents := txSpanEvents(recorder); !slices.Equal(gotEvents, tc.wantEvents) {
+				t.Errorf("span events:\n\twant: %q\n\t got: %q", tc.wantEvents, gotEvents)
+			}
+		})
+	}
+}
+
+func TestYieldTx(t *testing.T) {
+	t.Parallel()
+
+	db := sql.OpenDB(stubConnector{})
+	t.Cleanup(func() { _ = db.Close() })
+	ngy := nagaya.NewStd(db, nagaya.WithTenantSwitcher(&failingSwitcher{}))
+	got, err := nagaya.YieldTx(t.Context(), ngy, func(ctx context.Context) (nagaya.Tenant, error) {
+		if _, ok := nagaya.TxFromContext(ctx); !ok {
+			return "", errors.New("no transaction")
+		}
+		tenant, _ := nagaya.TenantFromContext(ctx)
+		return tenant, nil
+	}, nagaya.WithTenantDecisionResult(&nagaya.TenantDecisionResultChangeTenant{Tenant: "tenant_1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "tenant_1" {
+		t.Errorf("want: tenant_1, got: %s", got)
+	}
+}
+
+func txSpanEvents(recorder *tracetest.SpanRecorder) []string {
+	var events []string
+	for _, span := range recorder.Ended() {
+		if span.Name() != "Nagaya.Tx" {
+			continue
+		}
+		for _, ev := range span.Events() {
+			if strings.HasPrefix(ev.Name, "nagaya.tx.") {
+				events = append(events, ev.Name)
+			}
+		}
+	}
+	return events
+}
+
+// errAny tells the test case expects some error.
+var errAny = errors.New("any error")
+
+func TestMiddleware_withTransaction(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		opts       []nagaya.MiddlewareOption
+		handler    func(w http.ResponseWriter)
+		wantPanic  bool
+		wantTxErr  error
+		wantEvents []string
+	}{
+		{
+			name:       "commit",
+			opts:       []nagaya.MiddlewareOption{nagaya.WithTransaction()},
+			handler:    func(w http.ResponseWriter) { _, _ = w.Write([]byte("ok")) },
+			wantEvents: []string{"nagaya.tx.commit"},
+		},
+		{
+			name:       "commit without writing",
+			opts:       []nagaya.MiddlewareOption{nagaya.WithTransaction()},
+			handler:    func(http.ResponseWriter) {},
+			wantEvents: []string{"nagaya.tx.commit"},
+		},
+		{
+			name:       "rollback on error status",
+			opts:       []nagaya.MiddlewareOption{nagaya.WithTransaction()},
+			handler:    func(w http.ResponseWriter) { w.WriteHeader(http.StatusConflict) },
+			wantEvents: []string{"nagaya.tx.rollback"},
+		},
+		{
+			name:       "rollback on panic",
+			opts:       []nagaya.MiddlewareOption{nagaya.WithTransaction()},
+			handler:    func(http.ResponseWriter) { panic("oops") },
+			wantPanic:  true,
+			wantEvents: []string{"nagaya.tx.rollback"},
+		},
+		{
+			name:      "no transaction",
+			handler:   func(http.ResponseWriter) {},
+			wantTxErr: nagaya.ErrNoTxBound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			db := sql.OpenDB(stubConnector{})
+			t.Cleanup(func() { _ = db.Close() })
+			ngy := nagaya.NewStd(db, nagaya.WithTenantSwitcher(&failingSwitcher{}), nagaya.WithTracerProvider(tp))
+			opts := append([]nagaya.MiddlewareOption{nagaya.DecideTenantFromHeader("tenant-id")}, tc.opts...)
+			var txErr error
+			handler := nagaya.Middleware(ngy, opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, txErr = ngy.ObtainTx(r.Context())
+				tc.handler(w)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("tenant-id", "tenant_1")
+			func() {
+				defer func() {
+					if rec := recover(); (rec != nil) != tc.wantPanic {
+						t.Errorf("panic: %v", rec)
+					}
+				}()
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}()
+			if !errors.Is(txErr, tc.wantTxErr) {
+				t.Errorf("ObtainTx:\n\twant: %v\n\t got: %v", tc.wantTxErr, txErr)
+			}
+			if gotEvents := txSpanEvents(recorder); !slices.Equal(gotEvents, tc.wantEvents) {
+				t.Errorf("span events:\n\twant: %q\n\t got: %q", tc.wantEvents, gotEvents)
+			}
+		})
+	}
+}
+
+var errCommit = errors.New("commit failed")
+
+// failingCommitConnector is a [driver.Connector] whose transactions fail to commit.
+type failingCommitConnector struct{ stubConnector }
+
+func (failingCommitConnector) Connect(context.Context) (driver.Conn, error) {
+	return failingCommitConn{}, nil
+}
+
+type failingCommitConn struct{ stubConn }
+
+func (failingCommitConn) Begin() (driver.Tx, error) { return failingCommitTx{}, nil }
+
+type failingCommitTx struct{ stubTx }
+
+func (failingCommitTx) Commit() error { return errCommit }
+
+func TestMiddleware_withTransaction_commitFailure(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		handler func(w http.ResponseWriter)
+	}{
+		{name: "write", handler: func(w http.ResponseWriter) { _, _ = w.Write([]byte("ok")) }},
+		{name: "write header", handler: func(w http.ResponseWriter) { w.WriteHeader(http.StatusCreated) }},
+		{name: "without writing", handler: func(http.ResponseWriter) {}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ngy := nagaya.NewStd(openStubDBForTesting(t, failingCommitConnector{}), nagaya.WithTenantSwitcher(&failingSwitcher{}))
+			var gotErr error
+			errorHandler := func(w http.ResponseWriter, _ *http.Request, err error) {
+				gotErr = err
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			handler := nagaya.Middleware(ngy, nagaya.DecideTenantFromHeader("tenant-id"), nagaya.WithTransaction(), nagaya.WithErrorHandler(errorHandler))(
+				http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { tc.handler(w) }))
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("tenant-id", "tenant_1")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status:\n\twant: %d\n\t got: %d", http.StatusInternalServerError, rec.Code)
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("the response of the handler must be discarded but got %q", rec.Body.String())
+			}
+			if !errors.Is(gotErr, errCommit) {
+				t.Errorf("error:\n\twant: %v\n\t got: %v", errCommit, gotErr)
+			}
+		})
+	}
+}